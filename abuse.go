@@ -0,0 +1,54 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// emailPattern matches an email address inside a whois line
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+// AbuseInfo represents the abuse contact published for an ASN or prefix
+type AbuseInfo struct {
+	ASN          string  `json:"asn"`
+	AbuseContact *string `json:"abuse_contact"`
+	Note         string  `json:"note,omitempty"`
+}
+
+// abuseContact returns the first email address on a whois line mentioning
+// abuse, such as RIPE's abuse-mailbox or ARIN's OrgAbuseEmail
+func abuseContact(whois string) (string, bool) {
+	for _, line := range strings.Split(whois, "\n") {
+		if !strings.Contains(strings.ToLower(line), "abuse") {
+			continue
+		}
+		if email := emailPattern.FindString(line); email != "" {
+			return email, true
+		}
+	}
+	return "", false
+}
+
+// queryAbuse extracts the abuse contact from the whois section of an ASN, IP
+// or network block page. The ASN of an IP or network block is the first
+// announcing ASN shown on its page.
+func queryAbuse(doc *goquery.Document, q query) AbuseInfo {
+	info := AbuseInfo{ASN: strings.ToUpper(q.Value)}
+	switch q.Type {
+	case "ip":
+		info.ASN = strings.TrimSpace(doc.Find("#ipinfo tbody tr td").First().Text())
+	case "net":
+		info.ASN = strings.TrimSpace(doc.Find("#netinfo tbody tr td").First().Text())
+	}
+
+	whois := doc.Find("#whois pre").Text()
+	if contact, ok := abuseContact(whois); ok {
+		info.AbuseContact = &contact
+	} else {
+		info.Note = "no abuse contact published in whois"
+	}
+
+	return info
+}
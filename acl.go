@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// PrefixList is the list of prefixes an ASN announces, printed one per line
+// with -acl for firewall ACLs and BGP filters
+type PrefixList struct {
+	ASN      string   `json:"asn"`
+	Prefixes []string `json:"prefixes"`
+}
+
+// families maps the accepted -family values to the address family, 4 or 6
+var families = map[string]int{
+	"4": 4, "v4": 4, "ipv4": 4,
+	"6": 6, "v6": 6, "ipv6": 6,
+}
+
+// aclFamily returns the address family -family keeps, 0 for both
+func aclFamily() (int, error) {
+	if opts.family == "" {
+		return 0, nil
+	}
+	family, ok := families[strings.ToLower(opts.family)]
+	if !ok {
+		return 0, fmt.Errorf("unsupported -family %q, only 4 and 6 are", opts.family)
+	}
+	return family, nil
+}
+
+// asnPrefixes returns the IPv4 and IPv6 prefixes announced by an ASN,
+// normalized and without duplicates, keeping those of the family, 0 for
+// both, that pass the prefix length and -prefix-category filters. With -aggregate, the prefixes
+// are merged into the fewest covering the same addresses.
+func asnPrefixes(doc *goquery.Document, family int) []netip.Prefix {
+	var prefixes []netip.Prefix
+	seen := map[netip.Prefix]bool{}
+	eachPrefixRow(doc, func(row *goquery.Selection, table, category string) {
+		if !matchCategory(category) {
+			return
+		}
+		prefix, err := netip.ParsePrefix(cellText(row, 0, "prefix"))
+		if err != nil {
+			return
+		}
+		prefix = prefix.Masked()
+		if family == 4 && !prefix.Addr().Is4() || family == 6 && prefix.Addr().Is4() ||
+			!matchPrefixLen(prefix.String()) || seen[prefix] {
+			return
+		}
+		seen[prefix] = true
+		prefixes = append(prefixes, prefix)
+	})
+	if opts.aggregate {
+		prefixes = aggregatePrefixes(prefixes)
+	}
+	return prefixes
+}
+
+// queryACL lists the prefixes announced by an ASN of the -family, sorted
+func queryACL(doc *goquery.Document, q query) PrefixList {
+	// runQuery has checked -family already
+	family, _ := aclFamily()
+	prefixes := asnPrefixes(doc, family)
+	sortPrefixes(prefixes)
+
+	list := PrefixList{ASN: strings.ToUpper(q.Value), Prefixes: []string{}}
+	for _, prefix := range prefixes {
+		list.Prefixes = append(list.Prefixes, prefix.String())
+	}
+	return list
+}
+
+// sortPrefixes orders the prefixes IPv4 first, then by address and length
+func sortPrefixes(prefixes []netip.Prefix) {
+	sort.Slice(prefixes, func(i, j int) bool {
+		a, b := prefixes[i], prefixes[j]
+		if a.Addr().Is4() != b.Addr().Is4() {
+			return a.Addr().Is4()
+		}
+		if c := a.Addr().Compare(b.Addr()); c != 0 {
+			return c < 0
+		}
+		return a.Bits() < b.Bits()
+	})
+}
+
+// aggregatePrefixes drops the prefixes covered by another one and merges the
+// two halves of a prefix into it, until nothing is left to merge
+func aggregatePrefixes(prefixes []netip.Prefix) []netip.Prefix {
+	// shorter prefixes first, so that covered ones find their cover kept
+	sort.Slice(prefixes, func(i, j int) bool { return prefixes[i].Bits() < prefixes[j].Bits() })
+	set := map[netip.Prefix]bool{}
+	for _, prefix := range prefixes {
+		if !covered(set, prefix) {
+			set[prefix] = true
+		}
+	}
+
+	for merged := true; merged; {
+		merged = false
+		for prefix := range set {
+			if prefix.Bits() == 0 {
+				continue
+			}
+			sibling := netip.PrefixFrom(flipBit(prefix.Addr(), prefix.Bits()-1), prefix.Bits())
+			if set[sibling] {
+				delete(set, prefix)
+				delete(set, sibling)
+				set[netip.PrefixFrom(prefix.Addr(), prefix.Bits()-1).Masked()] = true
+				merged = true
+				break
+			}
+		}
+	}
+
+	aggregated := make([]netip.Prefix, 0, len(set))
+	for prefix := range set {
+		aggregated = append(aggregated, prefix)
+	}
+	return aggregated
+}
+
+// covered reports whether a prefix of the set holds every address of prefix
+func covered(set map[netip.Prefix]bool, prefix netip.Prefix) bool {
+	for bits := prefix.Bits(); bits >= 0; bits-- {
+		if set[netip.PrefixFrom(prefix.Addr(), bits).Masked()] {
+			return true
+		}
+	}
+	return false
+}
+
+// flipBit returns the address with the given bit flipped, counting from the
+// most significant one
+func flipBit(addr netip.Addr, bit int) netip.Addr {
+	if addr.Is4() {
+		b := addr.As4()
+		b[bit/8] ^= 0x80 >> (bit % 8)
+		return netip.AddrFrom4(b)
+	}
+	b := addr.As16()
+	b[bit/8] ^= 0x80 >> (bit % 8)
+	return netip.AddrFrom16(b)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestQueryACL(t *testing.T) {
+	defer func(saved options) { opts = saved }(opts)
+	tests := []struct {
+		family    string
+		aggregate bool
+		maxLen    int
+		want      []string
+	}{
+		{"", false, 0, []string{"192.0.2.0/24", "192.0.2.64/26", "198.51.100.0/25", "198.51.100.128/25",
+			"203.0.113.0/24", "2001:db8::/33", "2001:db8:8000::/33", "2001:db8:ffff::/48"}},
+		{"4", false, 0, []string{"192.0.2.0/24", "192.0.2.64/26", "198.51.100.0/25", "198.51.100.128/25",
+			"203.0.113.0/24"}},
+		// the /26 inside the /24 is dropped and the two /25 halves merged
+		{"4", true, 0, []string{"192.0.2.0/24", "198.51.100.0/24", "203.0.113.0/24"}},
+		{"6", true, 0, []string{"2001:db8::/32"}},
+		{"4", false, 24, []string{"192.0.2.0/24", "203.0.113.0/24"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("family %q aggregate %v max %d", tt.family, tt.aggregate, tt.maxLen), func(t *testing.T) {
+			opts = options{family: tt.family, aggregate: tt.aggregate, maxPrefixLen: tt.maxLen}
+			got := queryACL(loadFixture(t, "asn-acl.html"), query{Type: "asn", Value: "AS64500"})
+			if !slices.Equal(got.Prefixes, tt.want) {
+				t.Errorf("got %q, want %q", got.Prefixes, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// bareWriter prints the rows of every result of the run as one flat JSON
+// array, for consumers that always want an array whether one query ran or
+// many. The rows of a list are added one by one and any other result as a
+// row of its own. The array is one document, so it is written on Close.
+type bareWriter struct {
+	w    io.Writer
+	rows []json.RawMessage
+}
+
+// newBareWriter returns a bare writer to w
+func newBareWriter(w io.Writer) *bareWriter {
+	return &bareWriter{w: w, rows: []json.RawMessage{}}
+}
+
+// Write implements resultWriter
+func (b *bareWriter) Write(_ query, data interface{}) error {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Slice {
+		return b.add(data)
+	}
+	for i := 0; i < v.Len(); i++ {
+		if err := b.add(v.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add appends the JSON of a row to the array
+func (b *bareWriter) add(row interface{}) error {
+	jsonData, err := encodeJSON(row)
+	if err != nil {
+		return err
+	}
+	b.rows = append(b.rows, jsonData)
+	return nil
+}
+
+// Close implements resultWriter
+func (b *bareWriter) Close() error {
+	jsonData, err := json.Marshal(b.rows)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(b.w, string(jsonData))
+	return err
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// readBatchJSON reads a -batch-json file, a JSON array of objects holding
+// the type and value of a query each, as produced by JSON pipelines. Every
+// entry is checked and the malformed ones are reported together by their
+// index, from 0, rather than skipped.
+func readBatchJSON(r io.Reader) ([]query, error) {
+	var entries []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("-batch-json: want a JSON array of queries: %w", err)
+	}
+
+	var queries []query
+	var errs []error
+	for i, entry := range entries {
+		q, err := batchEntry(entry)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("-batch-json: entry %d: %w", i, err))
+			continue
+		}
+		queries = append(queries, q)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return queries, nil
+}
+
+// batchEntry decodes and checks an entry of a -batch-json array
+func batchEntry(entry json.RawMessage) (query, error) {
+	var q query
+	if err := json.Unmarshal(entry, &q); err != nil {
+		return q, fmt.Errorf("want an object with a type and a value: %w", err)
+	}
+	q.Type, q.Value = strings.ToLower(strings.TrimSpace(q.Type)), strings.TrimSpace(q.Value)
+	if _, ok := queryFuncs[q.Type]; !ok {
+		return q, fmt.Errorf("unknown type %q, want asn, ip, net, org or find-asn", q.Type)
+	}
+	if q.Value == "" {
+		return q, errors.New("missing value")
+	}
+	return q, nil
+}
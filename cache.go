@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// cacheExt is the extension of cache entries. Entries being written have a
+// temporary name without it, so they are never read as a hit.
+const cacheExt = ".html"
+
+// validatorsExt is the extension of the file next to an entry holding the
+// validators of the page
+const validatorsExt = ".validators"
+
+// cacheValidators are the validators a page was served with, sent back to
+// revalidate the entry once it is older than the TTL. Both are kept as the
+// server wrote them, so the server only ever compares them with its own
+// values and a skewed local clock cannot cause a wrong match.
+type cacheValidators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// validatorsFrom returns the validators of a response
+func validatorsFrom(h http.Header) cacheValidators {
+	return cacheValidators{ETag: h.Get("ETag"), LastModified: h.Get("Last-Modified")}
+}
+
+// setConditional makes req conditional on the validators. The ETag is
+// preferred since it does not depend on any clock; the date is only sent
+// when the page had no ETag.
+func (v cacheValidators) setConditional(req *http.Request) {
+	switch {
+	case v.ETag != "":
+		req.Header.Set("If-None-Match", v.ETag)
+	case v.LastModified != "":
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
+}
+
+// diskCache keeps the pages fetched from the BGP website on disk, so that
+// repeated lookups within the TTL are served without a request
+type diskCache struct {
+	dir string
+	ttl time.Duration
+}
+
+// cache is the page cache of the current run, nil unless -cache-dir is set.
+// Every method is a no-op on a nil receiver.
+var cache *diskCache
+
+// cacheReadable reports whether the pages of the query of ctx may come from
+// the cache: not with -no-cache or -refresh, nor when -retries-on-empty runs
+// the query again
+func cacheReadable(ctx context.Context) bool {
+	return !opts.noCache && !opts.refresh && !refetching(ctx)
+}
+
+// newDiskCache returns a cache in dir, creating it when missing, or nil when
+// dir is empty
+func newDiskCache(dir string, ttl time.Duration) (*diskCache, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("-cache-dir: %w", err)
+	}
+	return &diskCache{dir: dir, ttl: ttl}, nil
+}
+
+// path returns the file of the cache entry for a URL
+func (c *diskCache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+cacheExt)
+}
+
+// get returns the cached page of a URL, unless there is none or it is older
+// than the TTL. A zero TTL never expires entries.
+func (c *diskCache) get(url string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	path := c.path(url)
+	info, err := os.Stat(path)
+	if err != nil || c.ttl > 0 && time.Since(info.ModTime()) > c.ttl {
+		return nil, false
+	}
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	return body, true
+}
+
+// stale returns the entry of a URL with its validators, so that an entry get
+// no longer serves can be revalidated rather than fetched again. Entries
+// without validators are not returned.
+func (c *diskCache) stale(url string) ([]byte, cacheValidators, bool) {
+	var v cacheValidators
+	if c == nil {
+		return nil, v, false
+	}
+
+	path := c.path(url)
+	data, err := os.ReadFile(strings.TrimSuffix(path, cacheExt) + validatorsExt)
+	if err != nil || json.Unmarshal(data, &v) != nil || v == (cacheValidators{}) {
+		return nil, v, false
+	}
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, v, false
+	}
+	return body, v, true
+}
+
+// refresh restarts the TTL of the entry of a URL, which the server confirmed
+// is still current
+func (c *diskCache) refresh(url string) error {
+	if c == nil {
+		return nil
+	}
+	now := time.Now()
+	return os.Chtimes(c.path(url), now, now)
+}
+
+// put stores the page of a URL and its validators, replacing any previous
+// entry. The entry only appears once it is complete: a write that fails or is
+// cancelled through ctx leaves no entry behind.
+func (c *diskCache) put(ctx context.Context, url string, body []byte, v cacheValidators) error {
+	if c == nil {
+		return nil
+	}
+
+	path := c.path(url)
+	if err := writeFileContext(ctx, path, body); err != nil {
+		return err
+	}
+
+	// the validators of the previous page must not outlive it, or they
+	// would revalidate the new page
+	validators := strings.TrimSuffix(path, cacheExt) + validatorsExt
+	var err error
+	if v != (cacheValidators{}) {
+		data, _ := json.Marshal(v)
+		if err = writeFileContext(ctx, validators, data); err == nil {
+			return nil
+		}
+	}
+	if rmErr := os.Remove(validators); rmErr != nil && !os.IsNotExist(rmErr) {
+		return rmErr
+	}
+	return err
+}
+
+// writeChunk is the size of the writes between checks for cancellation
+const writeChunk = 64 << 10
+
+// writeFileContext writes data to a temporary file next to path and renames it
+// over path, so a crash mid-write never leaves a truncated file behind. It
+// gives up as soon as ctx is done, removing the temporary file and leaving
+// path as it was.
+func writeFileContext(ctx context.Context, path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	for len(data) > 0 {
+		if err := ctx.Err(); err != nil {
+			tmp.Close()
+			return err
+		}
+		n := min(len(data), writeChunk)
+		if _, err := tmp.Write(data[:n]); err != nil {
+			tmp.Close()
+			return err
+		}
+		data = data[n:]
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
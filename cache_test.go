@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// cancelAfter is a context cancelled once Err has been called n times, so a
+// test can cancel at a given point of a write
+type cancelAfter struct {
+	context.Context
+	n int
+}
+
+func (c *cancelAfter) Err() error {
+	if c.n <= 0 {
+		return context.Canceled
+	}
+	c.n--
+	return nil
+}
+
+func TestCachePutCancelled(t *testing.T) {
+	const url = "https://bgp.he.net/AS13335"
+	old := []byte("<html>old</html>")
+	body := bytes.Repeat([]byte("x"), 3*writeChunk)
+
+	tests := []struct {
+		name     string
+		previous []byte
+		checks   int
+	}{
+		{"before the first chunk", nil, 0},
+		{"mid-write", nil, 2},
+		{"before the rename", nil, 3},
+		{"mid-write over an entry", old, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &diskCache{dir: t.TempDir()}
+			if tt.previous != nil {
+				if err := c.put(context.Background(), url, tt.previous, cacheValidators{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := c.put(&cancelAfter{context.Background(), tt.checks}, url, body, cacheValidators{ETag: `"v2"`})
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("put: got %v, want %v", err, context.Canceled)
+			}
+
+			got, ok := c.get(url)
+			switch {
+			case tt.previous == nil && ok:
+				t.Errorf("get: hit of %d bytes after a cancelled write", len(got))
+			case tt.previous != nil && !bytes.Equal(got, tt.previous):
+				t.Errorf("get: got %q, want the previous entry %q", got, tt.previous)
+			}
+			if _, v, ok := c.stale(url); ok {
+				t.Errorf("stale: got validators %+v of the cancelled write", v)
+			}
+
+			entries, err := os.ReadDir(c.dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := 0
+			if tt.previous != nil {
+				want = 1
+			}
+			if len(entries) != want {
+				var names []string
+				for _, e := range entries {
+					names = append(names, e.Name())
+				}
+				t.Errorf("cache dir holds %q, want %d entries", names, want)
+			}
+		})
+	}
+}
+
+// TestCacheRevalidation checks the freshness of cache entries against a
+// server whose clock is far off the local one: the TTL counts from the local
+// time the entry was written, and the validators go back as served
+func TestCacheRevalidation(t *testing.T) {
+	// years off the local clock either way
+	future := time.Now().AddDate(5, 0, 0).UTC().Format(http.TimeFormat)
+	past := time.Now().AddDate(-5, 0, 0).UTC().Format(http.TimeFormat)
+
+	tests := []struct {
+		name         string
+		etag         string
+		lastModified string
+		age          time.Duration
+		honor        bool // the server answers 304 to a conditional request
+		wantRequest  bool
+		wantHeader   string
+		wantValue    string
+		wantBody     string
+	}{
+		{name: "fresh despite a Date in the past", lastModified: past, age: 10 * time.Minute,
+			wantBody: "v1"},
+		{name: "ETag preferred over a future Last-Modified", etag: `"v1"`, lastModified: future,
+			age: 2 * time.Hour, honor: true, wantRequest: true,
+			wantHeader: "If-None-Match", wantValue: `"v1"`, wantBody: "v1"},
+		{name: "future Last-Modified sent back as served", lastModified: future, age: 2 * time.Hour,
+			honor: true, wantRequest: true, wantHeader: "If-Modified-Since", wantValue: future, wantBody: "v1"},
+		{name: "past Last-Modified sent back as served", lastModified: past, age: 2 * time.Hour,
+			honor: true, wantRequest: true, wantHeader: "If-Modified-Since", wantValue: past, wantBody: "v1"},
+		{name: "server ignoring the validators", etag: `"v1"`, age: 2 * time.Hour,
+			wantRequest: true, wantHeader: "If-None-Match", wantValue: `"v1"`, wantBody: "v2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			version, requests := "v1", 0
+			var seen http.Header
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				requests++
+				seen = r.Header.Clone()
+				w.Header().Set("Date", past)
+				if tt.etag != "" {
+					w.Header().Set("ETag", tt.etag)
+				}
+				if tt.lastModified != "" {
+					w.Header().Set("Last-Modified", tt.lastModified)
+				}
+				conditional := r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != ""
+				if tt.honor && conditional {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Write([]byte("<html><body>" + version + "</body></html>"))
+			}))
+			defer srv.Close()
+
+			defer func(saved options, savedCache *diskCache) { opts, cache = saved, savedCache }(opts, cache)
+			opts = options{}
+			cache = &diskCache{dir: t.TempDir(), ttl: time.Hour}
+			url := srv.URL + "/AS64500"
+
+			if _, _, err := queryParser(context.Background(), url); err != nil {
+				t.Fatal(err)
+			}
+			written := time.Now().Add(-tt.age)
+			if err := os.Chtimes(cache.path(url), written, written); err != nil {
+				t.Fatal(err)
+			}
+			mu.Lock()
+			version, requests = "v2", 0
+			mu.Unlock()
+
+			doc, status, err := queryParser(context.Background(), url)
+			if err != nil || status != http.StatusOK {
+				t.Fatalf("got status %d and %v", status, err)
+			}
+			if got := doc.Find("body").Text(); got != tt.wantBody {
+				t.Errorf("got page %q, want %q", got, tt.wantBody)
+			}
+			if got := requests == 1; got != tt.wantRequest {
+				t.Errorf("%d requests, want a request: %v", requests, tt.wantRequest)
+			}
+			if tt.wantHeader != "" && seen.Get(tt.wantHeader) != tt.wantValue {
+				t.Errorf("got %s %q, want %q", tt.wantHeader, seen.Get(tt.wantHeader), tt.wantValue)
+			}
+			if tt.etag != "" && seen.Get("If-Modified-Since") != "" {
+				t.Errorf("got If-Modified-Since %q along with the ETag", seen.Get("If-Modified-Since"))
+			}
+
+			// a revalidated or replaced entry is fresh again
+			body, ok := cache.get(url)
+			if !ok || !bytes.Contains(body, []byte(tt.wantBody)) {
+				t.Errorf("cache: got %q and %v, want a fresh entry of %q", body, ok, tt.wantBody)
+			}
+		})
+	}
+}
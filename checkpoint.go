@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// checkpoint records the queries of a run that completed successfully, so a
+// restarted run with the same file can skip them. The file holds one JSON
+// query per line, appended and synced as each query completes, so recording
+// a query costs one line however large the batch. JSON keeps a value with a
+// tab or newline, as -batch-json allows, on its line. A crash mid-append leaves at most a last line without its newline,
+// which is not counted as done and is cut off before the next append.
+type checkpoint struct {
+	path string
+	done map[query]bool
+	// size is the length of the file up to its last complete line
+	size int64
+	f    *os.File
+}
+
+// loadCheckpoint reads the checkpoint file at path. A missing file is an
+// empty checkpoint.
+func loadCheckpoint(path string) (*checkpoint, error) {
+	c := &checkpoint{path: path, done: map[query]bool{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// a last line without its newline is a torn append
+	complete := data[:bytes.LastIndexByte(data, '\n')+1]
+	c.size = int64(len(complete))
+	for _, line := range strings.Split(strings.TrimSuffix(string(complete), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		var q query
+		if err := json.Unmarshal([]byte(line), &q); err != nil {
+			return nil, fmt.Errorf("%s: malformed checkpoint line %q: %w", path, line, err)
+		}
+		c.done[q] = true
+	}
+	return c, nil
+}
+
+// has reports whether the query already completed in an earlier run
+func (c *checkpoint) has(q query) bool {
+	return c.done[q]
+}
+
+// add marks a query as done, appending it to the checkpoint file and syncing
+// the file before returning
+func (c *checkpoint) add(q query) error {
+	if c.done[q] {
+		return nil
+	}
+	if c.f == nil {
+		f, err := os.OpenFile(c.path, os.O_WRONLY|os.O_CREATE, 0o644)
+		if err != nil {
+			return err
+		}
+		// drops what a crash left of a line past the last complete one
+		if err := f.Truncate(c.size); err != nil {
+			f.Close()
+			return err
+		}
+		c.f = f
+	}
+
+	line, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if _, err := c.f.WriteAt(line, c.size); err != nil {
+		return err
+	}
+	if err := c.f.Sync(); err != nil {
+		return err
+	}
+	c.size += int64(len(line))
+	c.done[q] = true
+	return nil
+}
+
+// close closes the checkpoint file
+func (c *checkpoint) close() error {
+	if c == nil || c.f == nil {
+		return nil
+	}
+	return c.f.Close()
+}
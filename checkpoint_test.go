@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpointAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.done")
+	// a crash cut the last append short
+	if err := os.WriteFile(path, []byte(`{"type":"asn","value":"AS13335"}`+"\n"+`{"type":"ip","val`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.has(query{Type: "asn", Value: "AS13335"}) {
+		t.Error("complete line not loaded")
+	}
+	if c.has(query{Type: "ip"}) {
+		t.Error("torn line loaded as done")
+	}
+
+	for _, q := range []query{{Type: "ip", Value: "1.1.1.1"}, {Type: "asn", Value: "AS13335"}, {Type: "net", Value: "1.0.0.0/24"}} {
+		if err := c.add(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"asn","value":"AS13335"}` + "\n" +
+		`{"type":"ip","value":"1.1.1.1"}` + "\n" +
+		`{"type":"net","value":"1.0.0.0/24"}` + "\n"
+	if string(data) != want {
+		t.Errorf("checkpoint file %q, want %q", data, want)
+	}
+}
+
+func TestCheckpointSeparators(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.done")
+	c, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// -batch-json takes any string as a value
+	queries := []query{{Type: "org", Value: "Example\tOrg"}, {Type: "org", Value: "Example\nOrg"}, {Type: "org", Value: "Example"}}
+	for _, q := range queries {
+		if err := c.add(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = loadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.done) != len(queries) {
+		t.Errorf("loaded %v, want %v", c.done, queries)
+	}
+	for _, q := range queries {
+		if !c.has(q) {
+			t.Errorf("%q not loaded", q.Value)
+		}
+	}
+}
@@ -0,0 +1,508 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// options holds the settings that control how queries are run and printed
+type options struct {
+	failFast        bool
+	keepGoing       bool
+	validate        bool
+	validateStrict  bool
+	validateOnly    bool
+	maxIdleConns    int
+	idleTimeout     time.Duration
+	http1           bool
+	countries       listValue
+	prefixCategory  listValue
+	dumpFlags       string
+	abuse           bool
+	deadline        time.Duration
+	minPrefixLen    int
+	maxPrefixLen    int
+	minPrefixLen6   int
+	maxPrefixLen6   int
+	output          string
+	outFile         string
+	rate            float64
+	serve           string
+	metrics         bool
+	selectTable     string
+	headers         headerValue
+	cookie          string
+	checkpoint      string
+	htmlFile        string
+	diff            string
+	atIX            bool
+	maxRuntime      time.Duration
+	config          string
+	timeout         time.Duration
+	timeoutASN      time.Duration
+	timeoutIP       time.Duration
+	timeoutNET      time.Duration
+	timeoutORG      time.Duration
+	proxy           string
+	caCert          string
+	noRedirects     bool
+	showRedirects   bool
+	userAgent       string
+	expand          bool
+	force           bool
+	expandLimit     int
+	debug           bool
+	get             string
+	retries         int
+	maxTotalRetries int
+	registries      registryValue
+	db              string
+	allowEmpty      bool
+	gzip            bool
+	graphs          bool
+	interactive     bool
+	preflight       bool
+	rawTable        bool
+	cacheDir        string
+	cacheTTL        time.Duration
+	onlyErrors      bool
+	compact         bool
+	strictHTML      bool
+	splitBy         string
+	outDir          string
+	stats           bool
+	renames         renameValue
+	baseURL         listValue
+	meta            bool
+	resolveNames    bool
+	echoQuery       bool
+	sort            string
+	hostConcurrency int
+	graphJSON       bool
+	asnDB           string
+	maxBodySize     int64
+	irr             bool
+	history         bool
+	since           string
+	lang            string
+	retryJitter     float64
+	single          bool
+	parseAnyway     bool
+	acl             bool
+	family          string
+	aggregate       bool
+	totalSpace      bool
+	parallel        int
+	order           string
+	peerEdges       bool
+	prefetch        string
+	batchJSON       bool
+	summaryOnly     bool
+	rib             string
+	bare            bool
+	retriesOnEmpty  int
+	showHeaders     listValue
+	noCache         bool
+	refresh         bool
+	keyBy           string
+}
+
+// opts is the set of options for the current run
+var opts options
+
+// register adds the flags shared by every command to fs
+func (o *options) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.config, "config", "", "Read flag defaults from this JSON file")
+	fs.BoolVar(&o.batchJSON, "batch-json", false, "Read batch files as a JSON array of {\"type\", \"value\"} objects")
+	fs.BoolVar(&o.expand, "expand", false, "Query every host IP of the network blocks in a batch")
+	fs.BoolVar(&o.force, "force", false, "Allow -expand of IPv4 blocks larger than a /24")
+	fs.IntVar(&o.expandLimit, "expand-limit", 0, "Maximum addresses of an IPv6 block -expand may query")
+	fs.BoolVar(&o.allowEmpty, "allow-empty", false, "Exit 0 when a query finds nothing, instead of 5")
+	fs.BoolVar(&o.onlyErrors, "only-errors", false, "Only print the queries that failed or found nothing, with the reason")
+	fs.BoolVar(&o.failFast, "fail-fast", false, "Stop at the first failed query")
+	fs.BoolVar(&o.keepGoing, "keep-going", false, "Continue past failed queries")
+	fs.StringVar(&o.checkpoint, "checkpoint", "", "Record completed queries in this file and skip them when resuming")
+	fs.DurationVar(&o.deadline, "deadline", 0, "Stop the run after this long and skip the remaining queries")
+	fs.DurationVar(&o.maxRuntime, "max-runtime", 0, "Hard cap on the runtime of the program, exiting with code 4")
+	fs.StringVar(&o.htmlFile, "html-file", "", "Parse this saved HTML page instead of fetching it")
+	fs.BoolVar(&o.rawTable, "raw-table", false, "Print the page's table as rows of cell texts, header row first")
+	fs.StringVar(&o.selectTable, "select-table", "", "Parse the nth table (from 1) or the table with this id for ip and org queries")
+	fs.BoolVar(&o.validate, "validate", false, "Warn about rows that fail field checks")
+	fs.BoolVar(&o.validateStrict, "validate-strict", false, "Like -validate, but fail the query on any warning")
+	fs.BoolVar(&o.validateOnly, "validate-only", false, "Check the shape of the targets and report the invalid ones, without querying")
+	fs.BoolVar(&o.acl, "acl", false, "Print only the prefixes announced by an ASN, one CIDR per line")
+	fs.StringVar(&o.family, "family", "", "Address family of the -acl prefixes, 4 or 6, both when empty")
+	fs.BoolVar(&o.aggregate, "aggregate", false, "Merge the -acl or -total-space prefixes into the fewest covering the same addresses")
+	fs.BoolVar(&o.totalSpace, "total-space", false, "Sum the IPv4 addresses and IPv6 /48s announced by an ASN")
+	fs.BoolVar(&o.peerEdges, "peer-edges", false, "Print the peers of an ASN as graph edges, one JSON object per line")
+	fs.BoolVar(&o.parseAnyway, "parse-anyway", false, "Parse pages served with a status other than 200 instead of failing the query")
+	fs.BoolVar(&o.strictHTML, "strict-html", false, "Fail the query when the page lacks the expected table, rows or cells")
+	fs.BoolVar(&o.compact, "compact", false, "Leave the fields holding empty or zero values out of the JSON")
+	fs.StringVar(&o.keyBy, "key-by", "", "Print the results as one JSON object keyed by the query (input)")
+	fs.BoolVar(&o.bare, "bare", false, "Print the rows of all results as one flat JSON array")
+	fs.BoolVar(&o.single, "single", false, "Print a result of exactly one row as that row's object instead of a list")
+	fs.BoolVar(&o.echoQuery, "echo-query", false, "Wrap each result with the query type and value it answers")
+	fs.BoolVar(&o.meta, "meta", false, "Wrap each result with where it came from, such as the host that served it")
+	fs.Var(&o.showHeaders, "show-headers", "Add these comma-separated response headers, or * for all, to the -meta")
+	fs.Var(&o.renames, "rename", "Rename an output field as old=new, may be repeated")
+	fs.StringVar(&o.output, "output", "json", "Output format (json, both, gob, prom, sections, geojson, tsv, sqlite)")
+	fs.BoolVar(&o.gzip, "gzip", false, "Gzip-compress the output, implied by an -o file ending in .gz")
+	fs.StringVar(&o.db, "db", "", "SQLite database file for -output sqlite")
+	fs.StringVar(&o.outFile, "o", "", "Write the results to this file instead of stdout")
+	fs.StringVar(&o.splitBy, "split-by", "", "Write the results to a file per query type (type) in -out-dir")
+	fs.StringVar(&o.outDir, "out-dir", "", "Directory of the -split-by files, created when needed")
+	fs.BoolVar(&o.abuse, "abuse", false, "Only print the abuse contact from the whois")
+	fs.BoolVar(&o.atIX, "at-ix", false, "Only print the exchanges an ASN peers at")
+	fs.BoolVar(&o.graphs, "graphs", false, "Only print the graph data URLs an ASN page links to")
+	fs.BoolVar(&o.graphJSON, "include-graph-json", false, "With -graphs, fetch the links serving JSON and embed their data")
+	fs.BoolVar(&o.summaryOnly, "summary-only", false, "Only print the name, country and prefix counts of an ASN")
+	fs.StringVar(&o.rib, "rib", "", "Diff the prefixes of an ASN against those it originates in this local RIB dump")
+	fs.BoolVar(&o.irr, "irr", false, "Only print the differences between the prefixes an ASN announces and has in the IRR")
+	fs.BoolVar(&o.history, "history", false, "Only print the prefix announcements and withdrawals in the history of an ASN or network block")
+	fs.StringVar(&o.since, "since", "", "With -history, only keep the events after this duration ago (72h, 7d) or date")
+	fs.BoolVar(&o.stats, "stats", false, "Only print the IPv4 and IPv6 peer counts of an ASN")
+	fs.BoolVar(&o.resolveNames, "resolve-names", false, "Add the name and country of the origin AS to IP and network rows")
+	fs.StringVar(&o.asnDB, "asn-db", "", "TSV file of AS numbers, names and countries used by -resolve-names before the site")
+	fs.Var(&o.countries, "country", "Only keep rows from these comma-separated country codes")
+	fs.Var(&o.prefixCategory, "prefix-category", "Only keep the ASN prefixes of these comma-separated categories, such as originated or transit")
+	fs.Var(&o.registries, "registry", "Only keep IP and network rows under these comma-separated registries")
+	fs.BoolVar(&o.debug, "debug", false, "Keep malformed rows in the output, flagged with malformed: true")
+	fs.StringVar(&o.diff, "diff", "", "Print the changes against the results saved in this JSON file")
+	fs.BoolVar(&o.interactive, "interactive", false, "Browse the results in a paged, sortable table in the terminal")
+	fs.StringVar(&o.sort, "sort", "", "Sort the rows of each result by this field, AS numbers numerically")
+	fs.StringVar(&o.get, "get", "", "Only print the values of this field, one per line")
+	fs.StringVar(&o.dumpFlags, "dump-flags", "", "Print the available flags in the given format (json) and exit")
+	fs.IntVar(&o.minPrefixLen, "min-prefixlen", 0, "Only keep IPv4 prefixes at least this long")
+	fs.IntVar(&o.maxPrefixLen, "max-prefixlen", 0, "Only keep IPv4 prefixes at most this long")
+	fs.IntVar(&o.minPrefixLen6, "min-prefixlen6", 0, "Only keep IPv6 prefixes at least this long")
+	fs.IntVar(&o.maxPrefixLen6, "max-prefixlen6", 0, "Only keep IPv6 prefixes at most this long")
+	fs.DurationVar(&o.timeout, "timeout", 30*time.Second, "Timeout of each request to the site, 0 for none")
+	fs.DurationVar(&o.timeoutASN, "timeout-asn", 0, "Timeout of the requests of asn queries, -timeout when 0")
+	fs.DurationVar(&o.timeoutIP, "timeout-ip", 0, "Timeout of the requests of ip queries, -timeout when 0")
+	fs.DurationVar(&o.timeoutNET, "timeout-net", 0, "Timeout of the requests of net queries, -timeout when 0")
+	fs.DurationVar(&o.timeoutORG, "timeout-org", 0, "Timeout of the requests of org and find-asn queries, -timeout when 0")
+	fs.StringVar(&o.cacheDir, "cache-dir", "", "Keep fetched pages in this directory and reuse them")
+	fs.BoolVar(&o.noCache, "no-cache", false, "Neither read nor write the -cache-dir cache in this run")
+	fs.BoolVar(&o.refresh, "refresh", false, "Fetch every page again and overwrite its -cache-dir entry")
+	fs.DurationVar(&o.cacheTTL, "cache-ttl", time.Hour, "How long cached pages are reused, 0 for ever")
+	fs.Var(&o.baseURL, "base-url", "Site to query instead of bgp.he.net, or comma-separated mirrors tried in turn")
+	fs.BoolVar(&o.noRedirects, "no-follow-redirects", false, "Fail requests answered with a redirect instead of following it")
+	fs.BoolVar(&o.showRedirects, "show-redirects", false, "Log every redirect of a request to stderr")
+	fs.StringVar(&o.caCert, "cacert", "", "PEM bundle of CA certificates trusted besides the system ones")
+	fs.StringVar(&o.proxy, "proxy", "", "Proxy URL for requests, instead of the HTTP(S)_PROXY environment")
+	fs.StringVar(&o.lang, "lang", "", "Accept-Language header sent with requests, such as en or de-CH,de;q=0.8")
+	fs.StringVar(&o.userAgent, "user-agent", "", "User-Agent header sent with requests")
+	fs.IntVar(&o.retries, "retries", 2, "Retries of a request on network errors and 429/5xx responses")
+	fs.IntVar(&o.retriesOnEmpty, "retries-on-empty", 0, "Fetch a query again up to this many times while its result is unexpectedly empty")
+	fs.Float64Var(&o.retryJitter, "retry-jitter", 0, "Fraction of the retry delay, between 0 and 1, to randomly add or remove")
+	fs.IntVar(&o.maxTotalRetries, "max-total-retries", 0, "Maximum retries over the whole run, 0 for no limit")
+	fs.BoolVar(&o.preflight, "preflight", false, "Check that the site answers a HEAD request before running the queries")
+	o.parallel = 1
+	fs.Var((*workersValue)(&o.parallel), "parallel", "Queries performed at the same time, or auto (0) for a number based on the CPUs and -rate")
+	fs.StringVar(&o.order, "order", "input", "Order of the results with -parallel (input, completion)")
+	fs.IntVar(&o.hostConcurrency, "per-host-concurrency", 2, "Maximum requests in flight to each host, 0 for no limit")
+	fs.Int64Var(&o.maxBodySize, "max-body-size", 32<<20, "Maximum size in bytes of a response, 0 for no limit")
+	fs.Float64Var(&o.rate, "rate", 0, "Maximum requests per second to the site, 0 for no limit")
+	fs.Var(&o.headers, "header", "Extra request header as 'Key: Value', may be repeated")
+	fs.StringVar(&o.cookie, "cookie", "", "Cookie header value sent with every request")
+	fs.IntVar(&o.maxIdleConns, "max-idle-conns", 10, "Maximum idle keep-alive connections")
+	fs.DurationVar(&o.idleTimeout, "idle-timeout", 90*time.Second, "How long idle connections are kept open")
+	fs.BoolVar(&o.http1, "http1", false, "Force HTTP/1.1 instead of negotiating HTTP/2")
+}
+
+// checkModes fails when more than one of the flags replacing the result of a
+// query with another view of its page is set, naming them
+func checkModes() error {
+	modes := []struct {
+		name string
+		set  bool
+	}{
+		{"-abuse", opts.abuse},
+		{"-at-ix", opts.atIX},
+		{"-graphs", opts.graphs},
+		{"-stats", opts.stats},
+		{"-summary-only", opts.summaryOnly},
+		{"-irr", opts.irr},
+		{"-rib", opts.rib != ""},
+		{"-acl", opts.acl},
+		{"-total-space", opts.totalSpace},
+		{"-peer-edges", opts.peerEdges},
+		{"-history", opts.history},
+		{"-raw-table", opts.rawTable},
+	}
+	var set []string
+	for _, m := range modes {
+		if m.set {
+			set = append(set, m.name)
+		}
+	}
+	if len(set) > 1 {
+		return fmt.Errorf("%s cannot be combined, set at most one", strings.Join(set, ", "))
+	}
+	return nil
+}
+
+// listValue is a flag holding a list of values, given comma-separated or by
+// repeating the flag
+type listValue []string
+
+// String implements flag.Value
+func (l *listValue) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set implements flag.Value
+func (l *listValue) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// Type names the kind of value for -dump-flags
+func (l *listValue) Type() string {
+	return "list"
+}
+
+// flagInfo describes a command-line flag for -dump-flags
+type flagInfo struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Default string `json:"default"`
+	Usage   string `json:"usage"`
+}
+
+// dumpFlags prints every flag of fs in the given format and returns the exit
+// code. Only json is supported.
+func dumpFlags(fs *flag.FlagSet, format string) int {
+	if format != "json" {
+		log.Printf("unsupported -dump-flags format %q", format)
+		return exitUsage
+	}
+
+	var flags []flagInfo
+	fs.VisitAll(func(f *flag.Flag) {
+		typ, usage := flag.UnquoteUsage(f)
+		if t, ok := f.Value.(interface{ Type() string }); ok {
+			typ = t.Type()
+		} else if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			typ = "bool"
+		}
+		flags = append(flags, flagInfo{Name: f.Name, Type: typ,
+			Default: f.DefValue, Usage: usage})
+	})
+
+	if err := printJSON(flags); err != nil {
+		log.Print(err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// command describes a subcommand of the CLI. Apart from batch, the name of a
+// command is also the query type it runs.
+type command struct {
+	name    string
+	args    string
+	usage   string
+	example string
+}
+
+// commands lists the subcommands in the order they are shown in the help
+var commands = []command{
+	{name: "asn", args: "<as>...", usage: "Query for ASN", example: "AS63293"},
+	{name: "ip", args: "<addr>...", usage: "Query for IP", example: "1.1.1.1"},
+	{name: "net", args: "<cidr>...", usage: "Query for network block", example: "41.223.111.0/22"},
+	{name: "org", args: "<name>...", usage: "Query for organization", example: "facebook"},
+	{name: "find-asn", args: "<name>...", usage: "Find the ASNs of an organization", example: "cloudflare"},
+	{name: "batch", args: "<file>...", usage: "Query every target listed in a file (- for stdin)", example: "targets.txt"},
+}
+
+// findCommand looks up a subcommand by name
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// parseInterspersed parses fs allowing flags and positional arguments to be
+// mixed, and returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		// errors exit through flag.ExitOnError
+		_ = fs.Parse(args)
+		if fs.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// runCommand parses the arguments of a subcommand and runs its queries
+func runCommand(cmd command, args []string) int {
+	fs := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	opts.register(fs)
+	fs.Usage = func() { showCommandHelp(cmd, fs) }
+
+	targets := parseInterspersed(fs, args)
+	if err := applyDefaults(fs); err != nil {
+		log.Print(err)
+		return exitUsage
+	}
+	if opts.expandLimit > maxExpandLimit {
+		log.Printf("-expand-limit must be at most %d", maxExpandLimit)
+		return exitUsage
+	}
+	if opts.dumpFlags != "" {
+		return dumpFlags(fs, opts.dumpFlags)
+	}
+	if len(targets) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	if cmd.name != "batch" {
+		var queries []query
+		for _, target := range targets {
+			queries = append(queries, query{Type: cmd.name, Value: target})
+		}
+		return run(queries, false)
+	}
+
+	var queries []query
+	for _, name := range targets {
+		batch, err := readBatch(name)
+		if err != nil {
+			log.Print(err)
+			return exitFailure
+		}
+		queries = append(queries, batch...)
+	}
+	return run(queries, true)
+}
+
+// runLegacy runs the flat -asn/-ip/-net/-org/-batch interface. It is kept for
+// backward compatibility and will be removed in the next release. Targets
+// given without a command are queried by their detected type.
+func runLegacy(args []string) int {
+	getASN := flag.String("asn", "", "Query for ASN (deprecated, use the asn command)")
+	getIP := flag.String("ip", "", "Query for IP (deprecated, use the ip command)")
+	getNET := flag.String("net", "", "Query for network block (deprecated, use the net command)")
+	getORG := flag.String("org", "", "Query for organization (deprecated, use the org command)")
+	getBatch := flag.String("batch", "", "Read targets from file, one per line (deprecated, use the batch command)")
+	flag.StringVar(&opts.serve, "serve", "", "Serve the queries as a JSON API on this address")
+	flag.BoolVar(&opts.metrics, "metrics", false, "Expose Prometheus metrics on /metrics in server mode")
+	flag.StringVar(&opts.prefetch, "prefetch", "", "Warm the -cache-dir cache with the targets of this file before serving")
+	wrap := flag.Bool("wrap", false, "Read JSON lines on stdin and print them as one JSON array")
+	getHelp := flag.Bool("h", false, "Show help message")
+	opts.register(flag.CommandLine)
+	flag.Usage = showHelpMessage
+	targets := parseInterspersed(flag.CommandLine, args)
+	if err := applyDefaults(flag.CommandLine); err != nil {
+		log.Print(err)
+		return exitUsage
+	}
+	if opts.expandLimit > maxExpandLimit {
+		log.Printf("-expand-limit must be at most %d", maxExpandLimit)
+		return exitUsage
+	}
+
+	if opts.dumpFlags != "" {
+		return dumpFlags(flag.CommandLine, opts.dumpFlags)
+	}
+	if opts.serve != "" {
+		return serve(opts.serve)
+	}
+	if *wrap {
+		return wrapLines(os.Stdin, os.Stdout)
+	}
+
+	// Show help message
+	if len(args) == 0 || *getHelp {
+		showHelpMessage()
+		return exitOK
+	}
+
+	var queries []query
+	legacy := []struct {
+		name  string
+		value string
+	}{
+		{"asn", *getASN},
+		{"ip", *getIP},
+		{"net", *getNET},
+		{"org", *getORG},
+	}
+	for _, l := range legacy {
+		if l.value == "" {
+			continue
+		}
+		log.Printf("-%s is deprecated, use '%s %s %s' instead",
+			l.name, os.Args[0], l.name, l.value)
+		queries = append(queries, query{Type: l.name, Value: l.value})
+	}
+
+	// bare targets run after the flag queries, each with its detected type
+	if len(targets) > 0 {
+		if cmd, ok := findCommand(targets[0]); ok {
+			log.Printf("the %s command must come before its options: %s %s [OPTIONS] <target>...",
+				cmd.name, os.Args[0], cmd.name)
+			return exitUsage
+		}
+	}
+	for _, target := range targets {
+		queries = append(queries, query{Type: detectQueryType(target), Value: target})
+	}
+
+	if *getBatch == "" {
+		return run(queries, false)
+	}
+
+	log.Printf("-batch is deprecated, use '%s batch %s' instead",
+		os.Args[0], *getBatch)
+	batch, err := readBatch(*getBatch)
+	if err != nil {
+		log.Print(err)
+		return exitFailure
+	}
+	return run(append(queries, batch...), true)
+}
+
+// showHelpMessage print the help message
+func showHelpMessage() {
+	fmt.Printf("Usage: %s <command> [OPTIONS] <target>...\n\n", os.Args[0])
+	fmt.Printf("Commands:\n")
+	for _, cmd := range commands {
+		fmt.Printf("  %-18s %s\n", cmd.name+" "+cmd.args, cmd.usage)
+	}
+	fmt.Printf("\nRun '%s <command> -h' for the options of a command.\n", os.Args[0])
+	fmt.Printf("\nLegacy options:\n")
+	flag.PrintDefaults()
+	fmt.Printf("\nExamples:")
+	for _, cmd := range commands {
+		fmt.Printf("\n  %s %s %s", os.Args[0], cmd.name, cmd.example)
+	}
+	fmt.Printf("\n  %s batch targets.txt -fail-fast", os.Args[0])
+	fmt.Printf("\n  %s -serve :8080 -rate 1\n", os.Args[0])
+}
+
+// showCommandHelp print the help message of a subcommand
+func showCommandHelp(cmd command, fs *flag.FlagSet) {
+	fmt.Printf("Usage: %s %s [OPTIONS] %s\n\n", os.Args[0], cmd.name, cmd.args)
+	fmt.Printf("%s\n\n", cmd.usage)
+	fmt.Printf("Options:\n")
+	fs.SetOutput(os.Stdout)
+	fs.PrintDefaults()
+	fmt.Printf("\nExample:\n  %s %s %s\n", os.Args[0], cmd.name, cmd.example)
+}
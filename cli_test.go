@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestCheckModes(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"none", nil, ""},
+		{"one", []string{"-acl"}, ""},
+		{"with a modifier", []string{"-graphs", "-include-graph-json"}, ""},
+		{"two", []string{"-acl", "-peer-edges"}, "-acl, -peer-edges cannot be combined, set at most one"},
+		{"several", []string{"-history", "-rib", "dump.txt", "-stats", "-irr"},
+			"-stats, -irr, -rib, -history cannot be combined, set at most one"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(saved options) { opts = saved }(opts)
+			opts = options{}
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			opts.register(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			err := checkModes()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("error %v, want none", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("error %v, want %q", err, tt.wantErr)
+			}
+			// run stops before opening the output or fetching anything
+			if code := run(nil, false); code != exitUsage {
+				t.Errorf("exit code %d, want %d", code, exitUsage)
+			}
+		})
+	}
+}
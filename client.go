@@ -0,0 +1,588 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// client is the HTTP client shared by all queries of a run
+var client = http.DefaultClient
+
+// limiter spaces out the requests of all queries of a run
+var limiter *rateLimiter
+
+// retries is the budget of retries shared by all queries of a run
+var retries *retryBudget
+
+// hostSlots caps the requests in flight to each host across all queries
+var hostSlots *hostLimiter
+
+// setupClient builds the shared HTTP client, rate limiter, retry budget,
+// per-host limit and page cache from the options, and loads the -asn-db names
+func setupClient() error {
+	for _, name := range []string{"asn", "ip", "net", "org"} {
+		if queryTimeouts()[name] < 0 {
+			return fmt.Errorf("-timeout-%s must not be negative", name)
+		}
+	}
+	if opts.noCache && opts.refresh {
+		return errors.New("-no-cache and -refresh are mutually exclusive")
+	}
+	if opts.refresh && opts.cacheDir == "" {
+		return errors.New("-refresh needs a -cache-dir")
+	}
+	if opts.retriesOnEmpty < 0 {
+		return fmt.Errorf("-retries-on-empty must not be negative, got %d", opts.retriesOnEmpty)
+	}
+	if opts.retryJitter < 0 || opts.retryJitter > 1 {
+		return fmt.Errorf("-retry-jitter must be between 0 and 1, got %g", opts.retryJitter)
+	}
+	if strings.ContainsAny(opts.lang, "\r\n") {
+		return fmt.Errorf("-lang must not contain line breaks")
+	}
+	for _, base := range opts.baseURL {
+		u, err := url.Parse(base)
+		if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("invalid -base-url %q", base)
+		}
+	}
+
+	var err error
+	client, err = newClient()
+	if err != nil {
+		return err
+	}
+	limiter = newRateLimiter(opts.rate)
+	retries = newRetryBudget(opts.maxTotalRetries)
+	hostSlots = newHostLimiter(opts.hostConcurrency)
+	if err := loadASNDB(opts.asnDB); err != nil {
+		return err
+	}
+	if err := loadRIB(opts.rib); err != nil {
+		return err
+	}
+	cache, err = newDiskCache(opts.cacheDir, opts.cacheTTL)
+	return err
+}
+
+// baseURLs returns the sites queries are sent to in order of preference, the
+// -base-url list or the BGP website
+func baseURLs() []string {
+	if len(opts.baseURL) == 0 {
+		return []string{BaseURL}
+	}
+	return opts.baseURL
+}
+
+// preflight sends a HEAD request to the BGP website, so that a run against a
+// site that is unreachable or failing stops at once instead of failing every
+// query. With mirrors, one of them answering is enough. The observed status is
+// logged.
+func preflight(ctx context.Context) error {
+	var errs []error
+	for _, base := range baseURLs() {
+		err := preflightHost(ctx, base)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// preflightHost sends the preflight request to one site
+func preflightHost(ctx context.Context, base string) error {
+	req, err := newRequest(ctx, http.MethodHead, base)
+	if err != nil {
+		return err
+	}
+
+	reqCtx, cancel := requestContext(ctx)
+	defer cancel()
+
+	start := time.Now()
+	res, err := client.Do(req.WithContext(reqCtx))
+	if err != nil {
+		return fmt.Errorf("-preflight: %s is unreachable: %w", base, err)
+	}
+	res.Body.Close()
+
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if res.StatusCode >= 400 {
+		return fmt.Errorf("-preflight: HEAD %s: status %d in %s", base, res.StatusCode, elapsed)
+	}
+	log.Printf("preflight: HEAD %s: status %d in %s", base, res.StatusCode, elapsed)
+	return nil
+}
+
+// newClient builds the shared HTTP client. Keep-alive connections are pooled
+// across queries and HTTP/2 is negotiated when the server supports it, unless
+// HTTP/1.1 is forced. Servers are trusted on the system roots and the
+// -cacert certificates.
+func newClient() (*http.Client, error) {
+	proxy := http.ProxyFromEnvironment
+	if opts.proxy != "" {
+		u, err := url.Parse(opts.proxy)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid -proxy URL %q", opts.proxy)
+		}
+		proxy = http.ProxyURL(u)
+	}
+	roots, err := loadRoots(opts.caCert)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:   !opts.http1,
+		MaxIdleConns:        opts.maxIdleConns,
+		MaxIdleConnsPerHost: opts.maxIdleConns,
+		IdleConnTimeout:     opts.idleTimeout,
+		TLSHandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:     &tls.Config{RootCAs: roots},
+	}
+
+	// a non-nil empty map stops the transport from upgrading to HTTP/2
+	if opts.http1 {
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	// requests are bound by the timeout of their query instead, see
+	// requestContext
+	return &http.Client{Transport: transport, CheckRedirect: checkRedirect}, nil
+}
+
+// maxRedirects is the number of redirects followed for a request, as many as
+// the default client follows
+const maxRedirects = 10
+
+// checkRedirect decides whether the client follows a redirect to req, after
+// the requests in via. With -show-redirects each hop is logged, and with
+// -no-follow-redirects the redirect response is returned as is, which then
+// fails the query on its status. A redirect off the site drops the
+// credentials of the run.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if opts.showRedirects {
+		status := 0
+		if req.Response != nil {
+			status = req.Response.StatusCode
+		}
+		log.Printf("redirect: %s -> %s (status %d)", via[len(via)-1].URL, req.URL, status)
+	}
+	if opts.noRedirects {
+		return http.ErrUseLastResponse
+	}
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	dropCredentials(req)
+	return nil
+}
+
+// loadRoots returns the system root certificates with those of the PEM
+// bundle at path added, or nil for the system roots alone when path is empty
+func loadRoots(path string) (*x509.CertPool, error) {
+	if path == "" {
+		return nil, nil
+	}
+	bundle, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("-cacert: %w", err)
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("-cacert: no PEM certificate found in %s", path)
+	}
+	return roots, nil
+}
+
+// timeoutKey is the context key of the request timeout of the running query
+type timeoutKey struct{}
+
+// queryTimeouts returns the -timeout-<type> override of each query type. An
+// unset override is 0. find-asn runs an organization search.
+func queryTimeouts() map[string]time.Duration {
+	return map[string]time.Duration{
+		"asn":      opts.timeoutASN,
+		"ip":       opts.timeoutIP,
+		"net":      opts.timeoutNET,
+		"org":      opts.timeoutORG,
+		"find-asn": opts.timeoutORG,
+	}
+}
+
+// withQueryTimeout returns a context whose requests time out after the
+// -timeout-<type> of the query type, or -timeout when it is not set
+func withQueryTimeout(ctx context.Context, queryType string) context.Context {
+	timeout := opts.timeout
+	if t := queryTimeouts()[queryType]; t > 0 {
+		timeout = t
+	}
+	return context.WithValue(ctx, timeoutKey{}, timeout)
+}
+
+// requestContext returns the context of a single request, bound by the
+// timeout of the query of ctx, or -timeout outside of a query. A zero timeout
+// leaves the request unbound.
+func requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout, ok := ctx.Value(timeoutKey{}).(time.Duration)
+	if !ok {
+		timeout = opts.timeout
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// siteURL reports whether a URL is on the BGP website, the BaseURL or a
+// -base-url mirror, with the same scheme and host
+func siteURL(u *url.URL) bool {
+	for _, base := range baseURLs() {
+		b, err := url.Parse(base)
+		if err == nil && strings.EqualFold(b.Scheme, u.Scheme) && strings.EqualFold(b.Host, u.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+// newRequest builds a request carrying the -lang value, and the -header,
+// -cookie and -user-agent values when it goes to the BGP website. Other
+// hosts, such as those graph links point to, never see them.
+func newRequest(ctx context.Context, method, rawURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if opts.lang != "" {
+		req.Header.Set("Accept-Language", opts.lang)
+	}
+	if !siteURL(req.URL) {
+		return req, nil
+	}
+
+	for key, values := range opts.headers.header {
+		for _, v := range values {
+			req.Header.Add(key, v)
+		}
+	}
+	if opts.cookie != "" {
+		req.Header.Add("Cookie", opts.cookie)
+	}
+	if opts.userAgent != "" {
+		req.Header.Set("User-Agent", opts.userAgent)
+	}
+	return req, nil
+}
+
+// dropCredentials removes the -header, -cookie and -user-agent values from a
+// request leaving the BGP website, which the client would otherwise copy
+// over on a redirect
+func dropCredentials(req *http.Request) {
+	if siteURL(req.URL) {
+		return
+	}
+	for key := range opts.headers.header {
+		req.Header.Del(key)
+	}
+	if opts.cookie != "" {
+		req.Header.Del("Cookie")
+	}
+	if opts.userAgent != "" {
+		req.Header.Del("User-Agent")
+	}
+}
+
+// headerValue is a repeatable flag holding extra request headers given as
+// "Key: Value"
+type headerValue struct {
+	header http.Header
+}
+
+// String implements flag.Value
+func (h *headerValue) String() string {
+	var lines []string
+	for key, values := range h.header {
+		for _, v := range values {
+			lines = append(lines, key+": "+v)
+		}
+	}
+	return strings.Join(lines, ", ")
+}
+
+// Set implements flag.Value, rejecting malformed headers
+func (h *headerValue) Set(value string) error {
+	key, v, ok := strings.Cut(value, ":")
+	if !ok {
+		return errors.New(`header must be "Key: Value"`)
+	}
+	key = strings.TrimSpace(key)
+	if !validHeaderKey(key) {
+		return fmt.Errorf("invalid header name %q", key)
+	}
+	if strings.ContainsAny(v, "\r\n") {
+		return fmt.Errorf("header %q must not contain line breaks", key)
+	}
+
+	if h.header == nil {
+		h.header = http.Header{}
+	}
+	h.header.Add(key, strings.TrimSpace(v))
+	return nil
+}
+
+// validHeaderKey reports whether key is a non-empty HTTP token
+func validHeaderKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		ok := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' ||
+			r >= '0' && r <= '9' || strings.ContainsRune("!#$%&'*+-.^_`|~", r)
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// rateLimiter spaces out requests to the BGP website so that at most rate
+// requests are sent per second, however many goroutines share it
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// newRateLimiter returns a limiter for the given requests per second, or nil
+// when rate is not positive
+func newRateLimiter(rate float64) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// wait blocks until the next request may be sent or the context is done. A
+// nil limiter never blocks.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	at := time.Now()
+	if l.next.After(at) {
+		at = l.next
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// hostLimiter caps the requests in flight to each host, so that the
+// concurrent requests of server mode never hammer one site or mirror
+type hostLimiter struct {
+	mu    sync.Mutex
+	max   int
+	slots map[string]chan struct{}
+}
+
+// newHostLimiter returns a limiter of max requests per host, or nil when max
+// is not positive
+func newHostLimiter(max int) *hostLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &hostLimiter{max: max, slots: map[string]chan struct{}{}}
+}
+
+// acquire blocks until a request to host may be sent or the context is done,
+// and returns the function releasing the slot. A nil limiter never blocks.
+func (l *hostLimiter) acquire(ctx context.Context, host string) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	l.mu.Lock()
+	slots, ok := l.slots[host]
+	if !ok {
+		slots = make(chan struct{}, l.max)
+		l.slots[host] = slots
+	}
+	l.mu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// retryBudget caps the retries of a whole run, so that a batch against a site
+// that is down fails fast instead of backing off on every target
+type retryBudget struct {
+	mu        sync.Mutex
+	left      int
+	exhausted bool
+}
+
+// newRetryBudget returns a budget of max retries, or nil when max is not
+// positive
+func newRetryBudget(max int) *retryBudget {
+	if max <= 0 {
+		return nil
+	}
+	return &retryBudget{left: max}
+}
+
+// take uses up one retry, reporting false once the budget is spent. A nil
+// budget never runs out.
+func (b *retryBudget) take() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.left > 0 {
+		b.left--
+		return true
+	}
+	if !b.exhausted {
+		b.exhausted = true
+		log.Printf("-max-total-retries of %d exhausted, not retrying anymore", opts.maxTotalRetries)
+	}
+	return false
+}
+
+// retryable reports whether a response status is worth retrying: the site is
+// throttling or failing rather than rejecting the query
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// jitterFloat returns the random numbers in [0, 1) spreading the retry delays,
+// replaced with a fixed sequence to reproduce the delays
+var jitterFloat = rand.Float64
+
+// sleep waits for d unless ctx is done first, replaced to retry without
+// waiting or to record the delays
+var sleep = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backoff returns the delay before the given retry, doubling from half a
+// second. With -retry-jitter, the delay is moved by up to that fraction
+// either way, so that queries failing together do not retry together.
+func backoff(retry int) time.Duration {
+	delay := 500 * time.Millisecond << retry
+	if opts.retryJitter > 0 {
+		delay += time.Duration(float64(delay) * opts.retryJitter * (2*jitterFloat() - 1))
+	}
+	return delay
+}
+
+// errStatus is the error of a page served with a status other than 200
+var errStatus = errors.New("unexpected status")
+
+// errBodyTooLarge is returned for a response larger than -max-body-size
+var errBodyTooLarge = errors.New("response body too large")
+
+// readBody reads a response body of at most -max-body-size bytes, so that a
+// misbehaving site or mirror cannot exhaust the memory with a huge response
+func readBody(r io.Reader) ([]byte, error) {
+	if opts.maxBodySize <= 0 {
+		return io.ReadAll(r)
+	}
+	body, err := io.ReadAll(io.LimitReader(r, opts.maxBodySize+1))
+	if err == nil && int64(len(body)) > opts.maxBodySize {
+		return nil, fmt.Errorf("%w, over the -max-body-size of %d bytes", errBodyTooLarge, opts.maxBodySize)
+	}
+	return body, err
+}
+
+// fetchError is the error of a request that failed for good, after any
+// retries. It tells a timeout apart from other failures and carries what
+// was tried, for messages that say exactly what failed.
+type fetchError struct {
+	url      string
+	attempts int
+	status   int // status of the last response, 0 when none was received
+	elapsed  time.Duration
+	err      error
+}
+
+// Error implements error
+func (e *fetchError) Error() string {
+	cause := e.err
+	// the URL is already part of the message
+	var urlErr *url.Error
+	if errors.As(cause, &urlErr) {
+		cause = urlErr.Err
+	}
+
+	what := cause.Error()
+	var netErr net.Error
+	if errors.Is(cause, context.DeadlineExceeded) || errors.As(cause, &netErr) && netErr.Timeout() {
+		what = "timed out"
+	}
+
+	msg := fmt.Sprintf("GET %s: %s after %s in %s", e.url, what, attempts(e.attempts),
+		e.elapsed.Round(time.Millisecond))
+	if e.status != 0 {
+		msg += fmt.Sprintf(", last status %d", e.status)
+	}
+	return msg
+}
+
+// Unwrap returns the error of the last attempt
+func (e *fetchError) Unwrap() error {
+	return e.err
+}
+
+// attempts formats a count of request attempts
+func attempts(n int) string {
+	if n == 1 {
+		return "1 attempt"
+	}
+	return fmt.Sprintf("%d attempts", n)
+}
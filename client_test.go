@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadBody(t *testing.T) {
+	defer func(saved options) { opts = saved }(opts)
+	tests := []struct {
+		limit   int64
+		size    int
+		tooLong bool
+	}{
+		{0, 1 << 20, false},
+		{1024, 1023, false},
+		{1024, 1024, false},
+		{1024, 1025, true},
+		{1024, 1 << 20, true},
+	}
+	for _, tt := range tests {
+		opts.maxBodySize = tt.limit
+		body, err := readBody(bytes.NewReader(make([]byte, tt.size)))
+		switch {
+		case tt.tooLong && !errors.Is(err, errBodyTooLarge):
+			t.Errorf("%d bytes over a limit of %d: got %v, want %v", tt.size, tt.limit, err, errBodyTooLarge)
+		case !tt.tooLong && (err != nil || len(body) != tt.size):
+			t.Errorf("%d bytes under a limit of %d: got %d bytes and %v", tt.size, tt.limit, len(body), err)
+		}
+	}
+}
+
+// TestMaxBodySize checks that a page over -max-body-size fails its query, as
+// a size error that is not retried
+func TestMaxBodySize(t *testing.T) {
+	var requests atomic.Int32
+	page := []byte("<html><body>" + strings.Repeat("x", 4096) + "</body></html>")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write(page)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		limit    int
+		wantCode int
+	}{
+		{len(page) - 1, exitFailure},
+		// the page fits, and holds no prefix
+		{len(page), exitEmpty},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.limit), func(t *testing.T) {
+			requests.Store(0)
+			code, out := runArgs(t, []query{{Type: "asn", Value: "AS64500"}}, true,
+				"-base-url", srv.URL, "-max-body-size", strconv.Itoa(tt.limit), "-retries", "2")
+			if code != tt.wantCode {
+				t.Errorf("exit code %d, want %d", code, tt.wantCode)
+			}
+			if n := requests.Load(); n != 1 {
+				t.Errorf("%d requests, want 1", n)
+			}
+			want := tt.wantCode == exitFailure
+			if got := strings.Contains(out, `"type":"size"`); got != want {
+				t.Errorf("size error in the output: got %v, want %v\n%s", got, want, out)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	defer func(saved options, savedJitter func() float64) { opts, jitterFloat = saved, savedJitter }(opts, jitterFloat)
+	tests := []struct {
+		retry  int
+		jitter float64
+		random float64
+		want   time.Duration
+	}{
+		{0, 0, 0.9, 500 * time.Millisecond},
+		{1, 0, 0.9, time.Second},
+		{3, 0, 0.9, 4 * time.Second},
+		// the random number moves the delay from -jitter to +jitter
+		{0, 0.5, 0, 250 * time.Millisecond},
+		{1, 0.5, 0.5, time.Second},
+		{2, 0.2, 0.75, 2200 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		opts.retryJitter = tt.jitter
+		jitterFloat = func() float64 { return tt.random }
+		if got := backoff(tt.retry); got != tt.want {
+			t.Errorf("retry %d, jitter %g at %g: got %s, want %s", tt.retry, tt.jitter, tt.random, got, tt.want)
+		}
+	}
+}
+
+// TestRetryIntervals checks the waits between the retries of a failing page,
+// with the random jitter and the sleep replaced so they are known and free
+func TestRetryIntervals(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	defer func(saved options, savedJitter func() float64, savedSleep func(context.Context, time.Duration) error) {
+		opts, jitterFloat, sleep = saved, savedJitter, savedSleep
+	}(opts, jitterFloat, sleep)
+	opts = options{retries: 3, retryJitter: 0.5}
+	random := []float64{0, 0.75, 0.5}
+	jitterFloat = func() float64 {
+		r := random[0]
+		random = random[1:]
+		return r
+	}
+	var waits []time.Duration
+	sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+
+	_, status, err := queryParser(context.Background(), srv.URL+"/AS64500")
+	var fetchErr *fetchError
+	if !errors.As(err, &fetchErr) || fetchErr.attempts != 4 || status != http.StatusServiceUnavailable {
+		t.Fatalf("got status %d and %v, want 4 attempts ending with status 503", status, err)
+	}
+	if n := requests.Load(); n != 4 {
+		t.Errorf("%d requests, want 4", n)
+	}
+	want := []time.Duration{250 * time.Millisecond, 1250 * time.Millisecond, 2 * time.Second}
+	if !slices.Equal(waits, want) {
+		t.Errorf("got waits %v, want %v", waits, want)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// compactJSON removes the empty fields from a JSON document: empty strings
+// and empty arrays or objects. false, 0 and null are kept, since a field such
+// as routed or abuse_contact means something when it holds them. The order
+// of the remaining fields is kept. Array elements are kept even when empty,
+// so rows keep their positions.
+func compactJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var b bytes.Buffer
+	if _, err := compactValue(dec, &b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// compactValue copies the next value of dec to b without its empty fields
+// and reports whether the value itself is empty
+func compactValue(dec *json.Decoder, b *bytes.Buffer) (bool, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return false, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '{' {
+			return compactObject(dec, b)
+		}
+		return compactArray(dec, b)
+	case string:
+		quoted, _ := json.Marshal(t)
+		b.Write(quoted)
+		return t == "", nil
+	case json.Number:
+		b.WriteString(t.String())
+		return false, nil
+	case bool:
+		fmt.Fprint(b, t)
+		return false, nil
+	case nil:
+		b.WriteString("null")
+		return false, nil
+	}
+	return false, fmt.Errorf("unexpected JSON token %v", tok)
+}
+
+// compactObject copies the fields of an object that are not empty
+func compactObject(dec *json.Decoder, b *bytes.Buffer) (bool, error) {
+	b.WriteByte('{')
+	fields := 0
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return false, err
+		}
+		key, _ := json.Marshal(tok)
+
+		var value bytes.Buffer
+		empty, err := compactValue(dec, &value)
+		if err != nil {
+			return false, err
+		}
+		if empty {
+			continue
+		}
+		if fields > 0 {
+			b.WriteByte(',')
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value.Bytes())
+		fields++
+	}
+	b.WriteByte('}')
+	_, err := dec.Token()
+	return fields == 0, err
+}
+
+// compactArray copies every element of an array, compacting each one
+func compactArray(dec *json.Decoder, b *bytes.Buffer) (bool, error) {
+	b.WriteByte('[')
+	elements := 0
+	for dec.More() {
+		if elements > 0 {
+			b.WriteByte(',')
+		}
+		if _, err := compactValue(dec, b); err != nil {
+			return false, err
+		}
+		elements++
+	}
+	b.WriteByte(']')
+	_, err := dec.Token()
+	return elements == 0, err
+}
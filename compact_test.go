@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestCompactJSON(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`{"ip":"192.0.2.1","routed":false,"origin":""}`, `{"ip":"192.0.2.1","routed":false}`},
+		{`{"email":"a@example.com","abuse_contact":null,"note":""}`, `{"email":"a@example.com","abuse_contact":null}`},
+		{`{"asn":"AS64500","peers":0,"ipv6_peers":0.0}`, `{"asn":"AS64500","peers":0,"ipv6_peers":0.0}`},
+		{`{"name":"hebgp.net","available":false}`, `{"name":"hebgp.net","available":false}`},
+		{`{"prefixes":[],"whois_sources":{},"as_path":["",""]}`, `{"as_path":["",""]}`},
+		// an object left without fields is empty itself
+		{`{"status":{"reason":"","note":""},"rows":[{"x":""},{}]}`, `{"rows":[{},{}]}`},
+		{`[{"a":1,"b":"é"},[]]`, `[{"a":1,"b":"é"},[]]`},
+	}
+	for _, tt := range tests {
+		got, err := compactJSON([]byte(tt.in))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.in, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
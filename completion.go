@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// flagValues lists the accepted values of enum-like flags for completion
+var flagValues = map[string][]string{
+	"dump-flags": {"json"},
+	"family":     {"4", "6"},
+	"key-by":     {"input"},
+	"order":      {"input", "completion"},
+	"output":     {"json", "both", "gob", "prom", "sections", "geojson", "tsv", "sqlite"},
+	"parallel":   {"auto"},
+	"registry":   {"arin", "ripe", "apnic", "lacnic", "afrinic"},
+	"split-by":   {"type"},
+}
+
+// completionArg reports whether args ask for a completion script with the
+// hidden -completion flag, and for which shell.
+func completionArg(args []string) (string, bool) {
+	if len(args) == 0 {
+		return "", false
+	}
+	name, value, hasValue := strings.Cut(strings.TrimLeft(args[0], "-"), "=")
+	if !strings.HasPrefix(args[0], "-") || name != "completion" {
+		return "", false
+	}
+	if !hasValue && len(args) > 1 {
+		value = args[1]
+	}
+	return value, true
+}
+
+// completionFlags returns the flags shared by every command, sorted by name
+func completionFlags() []*flag.Flag {
+	fs := flag.NewFlagSet("completion", flag.ContinueOnError)
+	var o options
+	o.register(fs)
+
+	var flags []*flag.Flag
+	fs.VisitAll(func(f *flag.Flag) { flags = append(flags, f) })
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	return flags
+}
+
+// isBoolFlag reports whether the flag takes no value
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// runCompletion prints the completion script for the given shell to stdout
+// and returns the exit code.
+func runCompletion(shell, prog string) int {
+	switch shell {
+	case "bash":
+		fmt.Print(bashCompletion(prog))
+	case "zsh":
+		fmt.Print(zshCompletion(prog))
+	default:
+		log.Printf("unsupported -completion shell %q, use bash or zsh", shell)
+		return exitUsage
+	}
+	return exitOK
+}
+
+// bashCompletion generates a bash completion script
+func bashCompletion(prog string) string {
+	var names, cmds []string
+	var b strings.Builder
+
+	for _, cmd := range commands {
+		cmds = append(cmds, cmd.name)
+	}
+	fmt.Fprintf(&b, "# bash completion for %s\n", prog)
+	fmt.Fprintf(&b, "_%s() {\n", prog)
+	b.WriteString("\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	b.WriteString("\tlocal prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	b.WriteString("\tcase \"$prev\" in\n")
+	for _, f := range completionFlags() {
+		names = append(names, "-"+f.Name)
+		if values, ok := flagValues[f.Name]; ok {
+			fmt.Fprintf(&b, "\t-%s)\n\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n\t\treturn\n\t\t;;\n",
+				f.Name, strings.Join(values, " "))
+		} else if !isBoolFlag(f) {
+			fmt.Fprintf(&b, "\t-%s)\n\t\treturn\n\t\t;;\n", f.Name)
+		}
+	}
+	b.WriteString("\tesac\n")
+	b.WriteString("\tif [[ $cur == -* ]]; then\n")
+	fmt.Fprintf(&b, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(names, " "))
+	b.WriteString("\telif [[ $COMP_CWORD -eq 1 ]]; then\n")
+	fmt.Fprintf(&b, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(cmds, " "))
+	b.WriteString("\telif [[ ${COMP_WORDS[1]} == batch ]]; then\n")
+	b.WriteString("\t\tCOMPREPLY=($(compgen -f -- \"$cur\"))\n")
+	b.WriteString("\tfi\n")
+	b.WriteString("}\n")
+	fmt.Fprintf(&b, "complete -F _%s %s\n", prog, prog)
+
+	return b.String()
+}
+
+// zshEscape escapes text for use inside a quoted _arguments spec
+func zshEscape(s string) string {
+	return strings.NewReplacer(`'`, `'\''`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+}
+
+// zshCompletion generates a zsh completion script
+func zshCompletion(prog string) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "#compdef %s\n\n", prog)
+	fmt.Fprintf(&b, "_%s() {\n", prog)
+	b.WriteString("\tlocal -a commands\n\tcommands=(\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "\t\t'%s:%s'\n", cmd.name, zshEscape(cmd.usage))
+	}
+	b.WriteString("\t)\n")
+	b.WriteString("\tif (( CURRENT == 2 )) && [[ $words[CURRENT] != -* ]]; then\n")
+	b.WriteString("\t\t_describe 'command' commands\n\t\treturn\n\tfi\n")
+	b.WriteString("\tlocal target='*:target: '\n")
+	b.WriteString("\t[[ $words[2] == batch ]] && target='*:file:_files'\n")
+	b.WriteString("\t_arguments -s \\\n")
+	for _, f := range completionFlags() {
+		_, usage := flag.UnquoteUsage(f)
+		spec := fmt.Sprintf("-%s[%s]", f.Name, zshEscape(usage))
+		if values, ok := flagValues[f.Name]; ok {
+			spec += fmt.Sprintf(":value:(%s)", strings.Join(values, " "))
+		} else if !isBoolFlag(f) {
+			spec += ":value: "
+		}
+		fmt.Fprintf(&b, "\t\t'%s' \\\n", spec)
+	}
+	b.WriteString("\t\t$target\n")
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "_%s \"$@\"\n", prog)
+
+	return b.String()
+}
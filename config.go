@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// envPrefix prefixes the environment variables that set flag defaults
+const envPrefix = "HEBGP_"
+
+// envName returns the environment variable setting the named flag, e.g.
+// HEBGP_USER_AGENT for -user-agent
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyDefaults fills the flags not given on the command line, first from the
+// environment and then from the -config file, so the precedence is
+// flag > env > file > default.
+func applyDefaults(fs *flag.FlagSet) error {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(envName(f.Name))
+		if err != nil || set[f.Name] || !ok {
+			return
+		}
+		if e := fs.Set(f.Name, value); e != nil {
+			err = fmt.Errorf("%s: %w", envName(f.Name), e)
+		}
+		set[f.Name] = true
+	})
+	if err != nil || opts.config == "" {
+		return err
+	}
+
+	return applyConfig(fs, opts.config, set)
+}
+
+// applyConfig sets the flags not in set from a JSON config file holding an
+// object keyed by flag name. Lists set repeatable flags once per item.
+func applyConfig(fs *flag.FlagSet, path string, set map[string]bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("-config: %w", err)
+	}
+
+	var config map[string]interface{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("-config: %s: %w", path, err)
+	}
+
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := config[key]
+		if fs.Lookup(key) == nil || key == "config" {
+			return fmt.Errorf("-config: %s: unknown key %q", path, key)
+		}
+		if set[key] {
+			continue
+		}
+
+		values := []interface{}{value}
+		if list, ok := value.([]interface{}); ok {
+			values = list
+		}
+		for _, v := range values {
+			var text string
+			switch v := v.(type) {
+			case string:
+				text = v
+			case float64:
+				text = strconv.FormatFloat(v, 'f', -1, 64)
+			case bool:
+				text = strconv.FormatBool(v)
+			default:
+				return fmt.Errorf("-config: %s: key %q must be a string, number, boolean or list of those", path, key)
+			}
+			if err := fs.Set(key, text); err != nil {
+				return fmt.Errorf("-config: %s: key %q: %w", path, key, err)
+			}
+		}
+	}
+	return nil
+}
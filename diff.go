@@ -0,0 +1,318 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// diffKeyFields are the row fields that identify a row across two results,
+// in the order they are joined into the row key
+var diffKeyFields = []string{"asn", "network", "prefix", "result", "ip"}
+
+// diffRow is a row present in only one of the compared results
+type diffRow struct {
+	Section string                 `json:"section,omitempty"`
+	Row     map[string]interface{} `json:"row"`
+}
+
+// diffChange is a row whose fields differ between the compared results
+type diffChange struct {
+	Section string                 `json:"section,omitempty"`
+	Before  map[string]interface{} `json:"before"`
+	After   map[string]interface{} `json:"after"`
+}
+
+// diffResult is printed in place of a result when -diff is set
+type diffResult struct {
+	Added   []diffRow    `json:"added"`
+	Removed []diffRow    `json:"removed"`
+	Changed []diffChange `json:"changed"`
+}
+
+// savedResults holds the results read from the -diff file. Results saved
+// with -echo-query are compared with the result of the same query, the
+// others in order.
+type savedResults struct {
+	byQuery map[query]interface{}
+	bare    []interface{}
+}
+
+// previousResults holds the saved results of the -diff file
+var previousResults savedResults
+
+// loadPrevious reads every JSON value of a file saved from an earlier run.
+// The results are taken out of their -meta or -echo-query envelope, and the
+// status lines of failed or skipped queries are left out.
+func loadPrevious(path string) (savedResults, error) {
+	saved := savedResults{byQuery: map[query]interface{}{}}
+	f, err := os.Open(path)
+	if err != nil {
+		return saved, fmt.Errorf("-diff: %w", err)
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	for {
+		var v interface{}
+		err := dec.Decode(&v)
+		if errors.Is(err, io.EOF) {
+			return saved, nil
+		}
+		if err != nil {
+			return saved, fmt.Errorf("-diff: %s: %w", path, err)
+		}
+
+		obj, _ := v.(map[string]interface{})
+		if savedStatus(obj) {
+			continue
+		}
+		results, ok := obj["results"]
+		if !ok {
+			saved.bare = append(saved.bare, v)
+			continue
+		}
+		q, _ := obj["query"].(map[string]interface{})
+		typ, _ := q["type"].(string)
+		value, _ := q["value"].(string)
+		if typ == "" {
+			saved.bare = append(saved.bare, results)
+			continue
+		}
+		saved.byQuery[query{Type: typ, Value: value}] = results
+	}
+}
+
+// savedStatus reports whether a saved value is the status line of a query
+// rather than its result
+func savedStatus(obj map[string]interface{}) bool {
+	for _, key := range []string{"type", "value", "status"} {
+		if _, ok := obj[key].(string); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// diffSections flattens a decoded result into its rows by section. Arrays
+// are rows of the top-level section "", arrays held by an object are
+// sections named after their key, and the remaining fields of an object make
+// up one row of section "".
+func diffSections(v interface{}) map[string][]map[string]interface{} {
+	sections := map[string][]map[string]interface{}{}
+	addRows := func(section string, rows []interface{}) {
+		for _, r := range rows {
+			if row, ok := r.(map[string]interface{}); ok {
+				sections[section] = append(sections[section], row)
+			}
+		}
+	}
+
+	switch res := v.(type) {
+	case []interface{}:
+		addRows("", res)
+	case map[string]interface{}:
+		rest := map[string]interface{}{}
+		for key, value := range res {
+			if rows, ok := value.([]interface{}); ok {
+				addRows(key, rows)
+			} else {
+				rest[key] = value
+			}
+		}
+		if len(rest) > 0 {
+			sections[""] = append(sections[""], rest)
+		}
+	}
+	return sections
+}
+
+// diffKey returns the key identifying a row across results. The key fields
+// go by their -rename names, as in the saved results.
+func diffKey(row map[string]interface{}) string {
+	var parts []string
+	for _, field := range diffKeyFields {
+		if name, ok := opts.renames[field]; ok {
+			field = name
+		}
+		if v, ok := row[field]; ok {
+			parts = append(parts, fmt.Sprint(v))
+		}
+	}
+	return strings.Join(parts, "|")
+}
+
+// rowText returns a row as JSON with its fields sorted, to compare rows
+func rowText(row map[string]interface{}) string {
+	jsonData, _ := json.Marshal(row)
+	return string(jsonData)
+}
+
+// diffValues compares the current result with a previous one, matching rows
+// of the same section by their key. Rows sharing a key are counted: those
+// found unchanged on both sides are matched first, the rest are paired as
+// changed in order, and any left over are added or removed. Rows are sorted
+// by section and key with a stable sort so the diff of unchanged input is
+// reproducible.
+func diffValues(previous, current interface{}) diffResult {
+	res := diffResult{Added: []diffRow{}, Removed: []diffRow{}, Changed: []diffChange{}}
+	before, after := diffSections(previous), diffSections(current)
+
+	index := func(rows []map[string]interface{}) map[string][]map[string]interface{} {
+		byKey := map[string][]map[string]interface{}{}
+		for _, row := range rows {
+			key := diffKey(row)
+			byKey[key] = append(byKey[key], row)
+		}
+		return byKey
+	}
+
+	sections := map[string]bool{}
+	for section := range before {
+		sections[section] = true
+	}
+	for section := range after {
+		sections[section] = true
+	}
+	for section := range sections {
+		old, now := index(before[section]), index(after[section])
+		keys := map[string]bool{}
+		for key := range old {
+			keys[key] = true
+		}
+		for key := range now {
+			keys[key] = true
+		}
+
+		for key := range keys {
+			removed, added := unmatchedRows(old[key], now[key])
+			n := min(len(removed), len(added))
+			for i := 0; i < n; i++ {
+				res.Changed = append(res.Changed, diffChange{Section: section, Before: removed[i], After: added[i]})
+			}
+			for _, row := range added[n:] {
+				res.Added = append(res.Added, diffRow{Section: section, Row: row})
+			}
+			for _, row := range removed[n:] {
+				res.Removed = append(res.Removed, diffRow{Section: section, Row: row})
+			}
+		}
+	}
+
+	sortRows := func(rows []diffRow) {
+		sort.SliceStable(rows, func(i, j int) bool {
+			if rows[i].Section != rows[j].Section {
+				return rows[i].Section < rows[j].Section
+			}
+			return diffKey(rows[i].Row) < diffKey(rows[j].Row)
+		})
+	}
+	sortRows(res.Added)
+	sortRows(res.Removed)
+	sort.SliceStable(res.Changed, func(i, j int) bool {
+		a, b := res.Changed[i], res.Changed[j]
+		if a.Section != b.Section {
+			return a.Section < b.Section
+		}
+		return diffKey(a.After) < diffKey(b.After)
+	})
+
+	return res
+}
+
+// unmatchedRows returns the rows of before and of after left once each row
+// is matched with an identical one on the other side, keeping their order
+func unmatchedRows(before, after []map[string]interface{}) (removed, added []map[string]interface{}) {
+	counts := map[string]int{}
+	for _, row := range after {
+		counts[rowText(row)]++
+	}
+	for _, row := range before {
+		if text := rowText(row); counts[text] > 0 {
+			counts[text]--
+		} else {
+			removed = append(removed, row)
+		}
+	}
+	for _, row := range after {
+		if text := rowText(row); counts[text] > 0 {
+			counts[text]--
+			added = append(added, row)
+		}
+	}
+	return removed, added
+}
+
+// diffNext compares the result of a query with its saved result from the
+// -diff file: the one saved with the same query, else the next one saved
+// without its query. Results missing from the file are compared against an
+// empty result. The result is encoded like the output, so results saved with
+// -rename or -compact compare field for field.
+func diffNext(q query, data interface{}) (diffResult, error) {
+	jsonData, err := encodeJSON(data)
+	if err != nil {
+		return diffResult{}, err
+	}
+	var current interface{}
+	if err := json.Unmarshal(jsonData, &current); err != nil {
+		return diffResult{}, err
+	}
+
+	previous, ok := previousResults.byQuery[q]
+	if !ok && len(previousResults.bare) > 0 {
+		previous, previousResults.bare = previousResults.bare[0], previousResults.bare[1:]
+	}
+	return diffValues(previous, current), nil
+}
+
+// colorDiff reports whether diffs are printed as colored text: only when the
+// results are written as plain JSON lines to a terminal. The writers of -bare,
+// -key-by, -split-by, -gzip, -o and the envelopes get the diff as a result.
+func colorDiff(out resultWriter) bool {
+	j, ok := out.(jsonWriter)
+	if !ok || j.w != io.Writer(os.Stdout) || opts.output != "json" || opts.meta || opts.echoQuery {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// printColorDiff prints a diff as lines of JSON rows, green for added, red
+// for removed and yellow for changed rows
+func printColorDiff(w io.Writer, d diffResult) error {
+	line := func(color, sign, section string, row interface{}) error {
+		jsonData, err := encodeJSON(row)
+		if err != nil {
+			return err
+		}
+		if section != "" {
+			section += " "
+		}
+		_, err = fmt.Fprintf(w, "\x1b[%sm%s %s%s\x1b[0m\n", color, sign, section, jsonData)
+		return err
+	}
+
+	for _, r := range d.Removed {
+		if err := line("31", "-", r.Section, r.Row); err != nil {
+			return err
+		}
+	}
+	for _, r := range d.Added {
+		if err := line("32", "+", r.Section, r.Row); err != nil {
+			return err
+		}
+	}
+	for _, c := range d.Changed {
+		if err := line("33", "~", c.Section, c.Before); err != nil {
+			return err
+		}
+		if err := line("33", ">", c.Section, c.After); err != nil {
+			return err
+		}
+	}
+	return nil
+}
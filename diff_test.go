@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// decodeJSON decodes a saved result as loadPrevious does
+func decodeJSON(t *testing.T, text string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(text), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestDiffValuesDuplicates(t *testing.T) {
+	previous := decodeJSON(t, `[
+		{"prefix": "192.0.2.0/24", "description": "A"},
+		{"prefix": "192.0.2.0/24", "description": "A"},
+		{"prefix": "198.51.100.0/24", "description": "B"}
+	]`)
+	current := decodeJSON(t, `[
+		{"prefix": "192.0.2.0/24", "description": "A"},
+		{"prefix": "198.51.100.0/24", "description": "B"},
+		{"prefix": "198.51.100.0/24", "description": "B"}
+	]`)
+
+	d := diffValues(previous, current)
+	if len(d.Added) != 1 || d.Added[0].Row["prefix"] != "198.51.100.0/24" {
+		t.Errorf("added %v, want the second 198.51.100.0/24 row", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].Row["prefix"] != "192.0.2.0/24" {
+		t.Errorf("removed %v, want the second 192.0.2.0/24 row", d.Removed)
+	}
+	if len(d.Changed) != 0 {
+		t.Errorf("changed %v, want none", d.Changed)
+	}
+}
+
+func TestDiffNextRenamed(t *testing.T) {
+	saved := opts
+	t.Cleanup(func() { opts = saved })
+	opts.renames = renameValue{"prefix": "cidr"}
+	opts.compact = true
+
+	// saved from a run with the same -rename and -compact
+	previousResults = savedResults{bare: []interface{}{decodeJSON(t, `[
+		{"cidr": "192.0.2.0/24", "description": "A", "rpki": "unknown", "table": "table_prefixes4", "category": "originated"},
+		{"cidr": "198.51.100.0/24", "description": "B", "rpki": "unknown", "table": "table_prefixes4", "category": "originated"}
+	]`)}}
+	d, err := diffNext(query{Type: "asn", Value: "AS64496"}, []ASNInfo{
+		{Prefix: "192.0.2.0/24", Description: "A", RPKI: "unknown", Table: "table_prefixes4", Category: "originated"},
+		{Prefix: "198.51.100.0/24", Description: "C", RPKI: "unknown", Table: "table_prefixes4", Category: "originated"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Added) != 0 || len(d.Removed) != 0 {
+		t.Errorf("added %v and removed %v, want none", d.Added, d.Removed)
+	}
+	if len(d.Changed) != 1 || d.Changed[0].After["description"] != "C" {
+		t.Errorf("changed %v, want the 198.51.100.0/24 description", d.Changed)
+	}
+}
+
+func TestDiffBatch(t *testing.T) {
+	page, err := os.ReadFile(filepath.Join("testdata", "asn-prefix-tables.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/AS64501" && r.URL.Path != "/AS64502" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(page)
+	}))
+	defer srv.Close()
+
+	// the failing query in the middle takes a status line in the saved file
+	queries := []query{{Type: "asn", Value: "AS64501"}, {Type: "asn", Value: "AS64496"}, {Type: "asn", Value: "AS64502"}}
+	code, out := runArgs(t, queries, true, "-base-url", srv.URL, "-retries", "0", "-echo-query")
+	if code != exitPartial {
+		t.Fatalf("saving: exit code %d, want %d", code, exitPartial)
+	}
+	saved := filepath.Join(t.TempDir(), "saved.json")
+	if err := os.WriteFile(saved, []byte(out), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, out = runArgs(t, queries, true, "-base-url", srv.URL, "-retries", "0", "-diff", saved)
+	if code != exitPartial {
+		t.Errorf("exit code %d, want %d", code, exitPartial)
+	}
+	diffs := 0
+	dec := json.NewDecoder(strings.NewReader(out))
+	for dec.More() {
+		var line map[string]json.RawMessage
+		if err := dec.Decode(&line); err != nil {
+			t.Fatalf("%v in %s", err, out)
+		}
+		if _, ok := line["status"]; ok {
+			continue
+		}
+		var d diffResult
+		raw, _ := json.Marshal(line)
+		if err := json.Unmarshal(raw, &d); err != nil {
+			t.Fatal(err)
+		}
+		// every query is compared with its own saved result
+		if len(d.Added) != 0 || len(d.Removed) != 0 || len(d.Changed) != 0 {
+			t.Errorf("diff %s, want none", raw)
+		}
+		diffs++
+	}
+	if diffs != 2 {
+		t.Errorf("got %d diffs, want 2", diffs)
+	}
+}
+
+func TestDiffBatchWithoutQuery(t *testing.T) {
+	saved := filepath.Join(t.TempDir(), "saved.json")
+	if err := os.WriteFile(saved, []byte("[]\n[]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	queries := []query{{Type: "asn", Value: "AS64501"}, {Type: "asn", Value: "AS64502"}}
+	if code, _ := runArgs(t, queries, true, "-diff", saved); code != exitUsage {
+		t.Errorf("exit code %d, want %d", code, exitUsage)
+	}
+}
+
+func TestDiffKeyBy(t *testing.T) {
+	page, err := os.ReadFile(filepath.Join("testdata", "asn-prefix-tables.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(page)
+	}))
+	defer srv.Close()
+
+	saved := filepath.Join(t.TempDir(), "saved.json")
+	if err := os.WriteFile(saved, []byte("[]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	queries := []query{{Type: "asn", Value: "AS64501"}}
+	code, out := runArgs(t, queries, false, "-base-url", srv.URL, "-diff", saved, "-key-by", "input")
+	if code != exitOK {
+		t.Fatalf("exit code %d, want %d", code, exitOK)
+	}
+
+	// the diff goes through the -key-by writer, keeping its single object
+	var got map[string]diffResult
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("%v in %s", err, out)
+	}
+	if d, ok := got["AS64501"]; !ok || len(d.Added) != 6 {
+		t.Errorf("got %s, want the 6 prefixes added under AS64501", out)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// refetchKey is the context key marking a query run again by
+// -retries-on-empty, whose pages are fetched from the site rather than from
+// the cache holding the empty page
+type refetchKey struct{}
+
+// refetching reports whether the query of ctx is run again after an empty
+// result
+func refetching(ctx context.Context) bool {
+	refetch, _ := ctx.Value(refetchKey{}).(bool)
+	return refetch
+}
+
+// unexpectedEmpty reports whether a result is empty although its page did not
+// say there was nothing to show. An IP whose page says it is not routed is
+// empty for good.
+func unexpectedEmpty(data interface{}) bool {
+	if res, ok := data.(IPResult); ok && res.Routed != nil && !*res.Routed {
+		return false
+	}
+	return emptyResult(data)
+}
+
+// runQueryRetrying runs a query, and with -retries-on-empty runs it again up
+// to that many times, with the backoff of failed requests, while its result
+// is unexpectedly empty, smoothing over pages the site transiently serves
+// with an empty table. The retries come out of the -max-total-retries budget.
+// The last result is kept even when empty, as is the one fetched before the
+// query is cancelled.
+func runQueryRetrying(ctx context.Context, q query) (interface{}, error) {
+	data, err := runQuery(ctx, q)
+	for retry := 0; err == nil && retry < opts.retriesOnEmpty && opts.htmlFile == "" &&
+		unexpectedEmpty(data) && retries.take(); retry++ {
+		delay := backoff(retry)
+		log.Printf("%s %s: empty result, fetching again in %s", q.Type, q.Value, delay.Round(time.Millisecond))
+		if sleep(ctx, delay) != nil {
+			return data, nil
+		}
+		*metaFrom(ctx) = resultMeta{}
+		data, err = runQuery(context.WithValue(ctx, refetchKey{}, true), q)
+	}
+	return data, err
+}
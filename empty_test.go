@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestRetriesOnEmpty checks that -retries-on-empty fetches an empty page
+// again at most n times, within -max-total-retries, and then accepts the
+// empty result
+func TestRetriesOnEmpty(t *testing.T) {
+	full, err := os.ReadFile(filepath.Join("testdata", "asn-prefix-tables.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty := []byte(`<html><body><table id="table_prefixes4"><tbody></tbody></table></body></html>`)
+
+	var requests, fullAfter atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		if after := fullAfter.Load(); after > 0 && n > after {
+			w.Write(full)
+			return
+		}
+		w.Write(empty)
+	}))
+	defer srv.Close()
+
+	defer func(saved func(context.Context, time.Duration) error) { sleep = saved }(sleep)
+	var waits atomic.Int32
+	sleep = func(ctx context.Context, d time.Duration) error {
+		waits.Add(1)
+		return nil
+	}
+
+	tests := []struct {
+		name      string
+		retries   int
+		budget    int
+		fullAfter int32 // the page is empty for this many requests, always when 0
+		args      []string
+		wantCode  int
+		requests  int32
+	}{
+		{name: "no retries", retries: 0, wantCode: exitEmpty, requests: 1},
+		{name: "empty up to the cap", retries: 3, wantCode: exitEmpty, requests: 4},
+		{name: "accepted with -allow-empty", retries: 2, args: []string{"-allow-empty"},
+			wantCode: exitOK, requests: 3},
+		{name: "capped by -max-total-retries", retries: 3, budget: 1, wantCode: exitEmpty, requests: 2},
+		{name: "full on the second fetch", retries: 3, fullAfter: 1, wantCode: exitOK, requests: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			waits.Store(0)
+			fullAfter.Store(tt.fullAfter)
+			args := append([]string{"-base-url", srv.URL, "-retries-on-empty", strconv.Itoa(tt.retries),
+				"-max-total-retries", strconv.Itoa(tt.budget)}, tt.args...)
+			code, out := runArgs(t, []query{{Type: "asn", Value: "AS64501"}}, false, args...)
+			if code != tt.wantCode {
+				t.Errorf("exit code %d, want %d", code, tt.wantCode)
+			}
+			if n := requests.Load(); n != tt.requests {
+				t.Errorf("%d requests, want %d", n, tt.requests)
+			}
+			if n := waits.Load(); n != tt.requests-1 {
+				t.Errorf("%d waits, want %d", n, tt.requests-1)
+			}
+			// the last result is printed, empty or not
+			if strings.TrimSpace(out) == "" {
+				t.Error("no output")
+			}
+		})
+	}
+}
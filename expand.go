@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// maxExpandV4 is the largest IPv4 block expanded without -force, a /24
+const maxExpandV4 = 256
+
+// maxExpandLimit bounds -expand-limit so IPv6 expansion stays small
+const maxExpandLimit = 65536
+
+// maxExpandForceV4 is the largest IPv4 block expanded even with -force, a /16
+const maxExpandForceV4 = 65536
+
+// expandQueries replaces the network block queries of a batch with one IP
+// query per host address. IPv4 blocks larger than a /24 need -force, which
+// still stops at a /16, and IPv6 blocks are only expanded up to an explicit
+// -expand-limit.
+func expandQueries(queries []query) ([]query, error) {
+	var expanded []query
+	for _, q := range queries {
+		if q.Type != "net" {
+			expanded = append(expanded, q)
+			continue
+		}
+
+		hosts, err := expandPrefix(q.Value)
+		if err != nil {
+			return nil, err
+		}
+		for _, host := range hosts {
+			expanded = append(expanded, query{Type: "ip", Value: host})
+		}
+	}
+	return expanded, nil
+}
+
+// expandPrefix returns the host addresses of a network block. The network and
+// broadcast addresses of IPv4 blocks larger than a /31 are left out.
+func expandPrefix(cidr string) ([]string, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("-expand: %w", err)
+	}
+	prefix = prefix.Masked()
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+
+	if prefix.Addr().Is4() {
+		if hostBits >= 32 || 1<<hostBits > maxExpandForceV4 {
+			return nil, fmt.Errorf("-expand: %s is larger than a /16, the largest IPv4 block expanded even with -force", cidr)
+		}
+		if hostBits > 8 && !opts.force {
+			return nil, fmt.Errorf("-expand: %s is larger than a /24, use -force to expand it", cidr)
+		}
+	} else {
+		if opts.expandLimit <= 0 {
+			return nil, fmt.Errorf("-expand: %s is IPv6, set -expand-limit to expand it", cidr)
+		}
+		if hostBits >= 32 || 1<<hostBits > opts.expandLimit {
+			return nil, fmt.Errorf("-expand: %s holds more than -expand-limit %d addresses",
+				cidr, opts.expandLimit)
+		}
+	}
+
+	var hosts []string
+	for addr := prefix.Addr(); addr.IsValid() && prefix.Contains(addr); addr = addr.Next() {
+		hosts = append(hosts, addr.String())
+	}
+	if prefix.Addr().Is4() && hostBits > 1 {
+		hosts = hosts[1 : len(hosts)-1]
+	}
+	return hosts, nil
+}
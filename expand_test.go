@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandPrefixLimits(t *testing.T) {
+	tests := []struct {
+		cidr      string
+		force     bool
+		wantHosts int
+		wantErr   string
+	}{
+		{"192.0.2.0/28", false, 14, ""},
+		{"192.0.2.0/31", false, 2, ""},
+		{"192.0.0.0/23", false, 0, "use -force"},
+		{"192.0.0.0/23", true, 510, ""},
+		{"10.0.0.0/16", true, 65534, ""},
+		// -force stops at a /16 rather than building millions of queries
+		{"10.0.0.0/15", true, 0, "larger than a /16"},
+		{"10.0.0.0/8", true, 0, "larger than a /16"},
+		{"0.0.0.0/0", true, 0, "larger than a /16"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cidr, func(t *testing.T) {
+			defer func(saved options) { opts = saved }(opts)
+			opts.force = tt.force
+			hosts, err := expandPrefix(tt.cidr)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(hosts) != tt.wantHosts {
+				t.Errorf("got %d hosts, want %d", len(hosts), tt.wantHosts)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// filterRows returns the rows for which keep returns true
+func filterRows[T any](rows []T, keep func(T) bool) []T {
+	var kept []T
+	for _, row := range rows {
+		if keep(row) {
+			kept = append(kept, row)
+		}
+	}
+	return kept
+}
+
+// matchCountry reports whether a row's country passes the -country filter.
+// Rows without a country never match a filter.
+func matchCountry(country string) bool {
+	if len(opts.countries) == 0 {
+		return true
+	}
+	for _, c := range opts.countries {
+		if country != "" && strings.EqualFold(c, country) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPrefixLens validates the mask length filters: each bound within the
+// lengths of its address family, and a minimum no longer than the maximum
+func checkPrefixLens() error {
+	bounds := []struct {
+		family   string
+		min, max int
+		bits     int
+	}{
+		{"", opts.minPrefixLen, opts.maxPrefixLen, 32},
+		{"6", opts.minPrefixLen6, opts.maxPrefixLen6, 128},
+	}
+	for _, b := range bounds {
+		for _, v := range []struct {
+			name string
+			len  int
+		}{{"-min-prefixlen" + b.family, b.min}, {"-max-prefixlen" + b.family, b.max}} {
+			if v.len < 0 || v.len > b.bits {
+				return fmt.Errorf("%s must be between 0 and %d, got %d", v.name, b.bits, v.len)
+			}
+		}
+		if b.max != 0 && b.min > b.max {
+			return fmt.Errorf("-min-prefixlen%s %d is longer than -max-prefixlen%s %d", b.family, b.min, b.family, b.max)
+		}
+	}
+	return nil
+}
+
+// matchPrefixLen reports whether a prefix passes the mask length filters of
+// its address family. IPv4 and IPv6 prefixes are bounded independently and a
+// zero bound is not checked. Prefixes that do not parse never match a filter.
+func matchPrefixLen(prefix string) bool {
+	if opts.minPrefixLen == 0 && opts.maxPrefixLen == 0 &&
+		opts.minPrefixLen6 == 0 && opts.maxPrefixLen6 == 0 {
+		return true
+	}
+
+	_, ipnet, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return false
+	}
+
+	min, max := opts.minPrefixLen, opts.maxPrefixLen
+	if ipnet.IP.To4() == nil {
+		min, max = opts.minPrefixLen6, opts.maxPrefixLen6
+	}
+	bits, _ := ipnet.Mask.Size()
+	return (min == 0 || bits >= min) && (max == 0 || bits <= max)
+}
+
+// filterResult drops the rows of a parsed result that do not pass the
+// post-scrape filters
+func filterResult(data interface{}) interface{} {
+	switch res := data.(type) {
+	case IPResult:
+		res.Announcement = filterRows(res.Announcement, func(r IPInfo) bool {
+			return matchCountry(r.Country) && matchRegistry(r.Registry)
+		})
+		return res
+	case []NETInfo:
+		return filterRows(res, func(r NETInfo) bool {
+			return matchCountry(r.Country) && matchPrefixLen(r.Network) &&
+				matchRegistry(r.Registry)
+		})
+	case []ASNInfo:
+		return filterRows(res, func(r ASNInfo) bool {
+			return matchCountry(r.Country) && matchPrefixLen(r.Prefix) &&
+				matchCategory(r.Category)
+		})
+	case []ORGInfo:
+		return filterRows(res, func(r ORGInfo) bool { return matchCountry(r.Country) })
+	}
+	return data
+}
+
+// dropMalformed counts the rows of a parsed result that were too short to
+// fill every field, and drops them unless -debug is set, in which case they
+// are kept with their malformed flag.
+func dropMalformed(data interface{}) (interface{}, int) {
+	count := 0
+	keep := func(malformed bool) bool {
+		if malformed {
+			count++
+		}
+		return !malformed || opts.debug
+	}
+
+	switch res := data.(type) {
+	case IPResult:
+		res.Announcement = filterRows(res.Announcement, func(r IPInfo) bool { return keep(r.Malformed) })
+		res.DNS = filterRows(res.DNS, func(r DNSInfo) bool { return keep(r.Malformed) })
+		return res, count
+	case []NETInfo:
+		return filterRows(res, func(r NETInfo) bool { return keep(r.Malformed) }), count
+	case []ASNInfo:
+		return filterRows(res, func(r ASNInfo) bool { return keep(r.Malformed) }), count
+	case []ORGInfo:
+		return filterRows(res, func(r ORGInfo) bool { return keep(r.Malformed) }), count
+	case []IXInfo:
+		kept := filterRows(res, func(r IXInfo) bool { return keep(r.Malformed) })
+		if kept == nil {
+			kept = []IXInfo{}
+		}
+		return kept, count
+	}
+	return data, count
+}
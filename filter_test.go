@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestCheckPrefixLens(t *testing.T) {
+	tests := []struct {
+		name                 string
+		min, max, min6, max6 int
+		wantErr              string
+	}{
+		{"unset", 0, 0, 0, 0, ""},
+		{"bounds", 16, 24, 32, 48, ""},
+		{"full lengths", 32, 32, 128, 128, ""},
+		{"min only", 24, 0, 48, 0, ""},
+		{"negative min", -1, 0, 0, 0, "-min-prefixlen must be between 0 and 32, got -1"},
+		{"v4 max too long", 0, 33, 0, 0, "-max-prefixlen must be between 0 and 32, got 33"},
+		{"v6 min negative", 0, 0, -8, 0, "-min-prefixlen6 must be between 0 and 128, got -8"},
+		{"v6 max too long", 0, 0, 0, 129, "-max-prefixlen6 must be between 0 and 128, got 129"},
+		{"v4 min over max", 25, 24, 0, 0, "-min-prefixlen 25 is longer than -max-prefixlen 24"},
+		{"v6 min over max", 0, 0, 64, 48, "-min-prefixlen6 64 is longer than -max-prefixlen6 48"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(saved options) { opts = saved }(opts)
+			opts.minPrefixLen, opts.maxPrefixLen = tt.min, tt.max
+			opts.minPrefixLen6, opts.maxPrefixLen6 = tt.min6, tt.max6
+			err := checkPrefixLens()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("error %v, want none", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("error %v, want %q", err, tt.wantErr)
+			}
+			if code := run(nil, false); code != exitUsage {
+				t.Errorf("exit code %d, want %d", code, exitUsage)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"log"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// FoundASN represents an AS matching an organization name
+type FoundASN struct {
+	ASN  string `json:"asn"`
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+// queryFindASN runs an organization search and keeps only the AS results,
+// for finding the ASN of a company by its name. Other kinds of results, and
+// AS results outside a -country filter, are dropped.
+func queryFindASN(doc *goquery.Document, q query) interface{} {
+	orgs, malformed := dropMalformed(queryORG(doc, q))
+	if malformed > 0 {
+		log.Printf("%s %s: %d malformed rows", q.Type, q.Value, malformed)
+	}
+
+	found := []FoundASN{}
+	for _, row := range orgs.([]ORGInfo) {
+		if row.Type == orgTypeASN && matchCountry(row.Country) {
+			found = append(found, FoundASN{ASN: row.Result, Name: row.Description, URL: row.URL})
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		log.Printf("%s %s: no AS matches", q.Type, q.Value)
+	case 1:
+	default:
+		log.Printf("%s %s: %d ASes match", q.Type, q.Value, len(found))
+	}
+	return found
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// countryCentroids holds the approximate centroid of each country, as
+// latitude and longitude, placing the rows of -output geojson by their
+// country code. The values come from the countries.csv table of Google's
+// DSPL canonical datasets, cut down to the countries networks are most often
+// registered in.
+var countryCentroids = map[string][2]float64{
+	"AD": {42.546245, 1.601554}, "AE": {23.424076, 53.847818}, "AF": {33.93911, 67.709953},
+	"AL": {41.153332, 20.168331}, "AM": {40.069099, 45.038189}, "AO": {-11.202692, 17.873887},
+	"AR": {-38.416097, -63.616672}, "AT": {47.516231, 14.550072}, "AU": {-25.274398, 133.775136},
+	"AZ": {40.143105, 47.576927}, "BA": {43.915886, 17.679076}, "BD": {23.684994, 90.356331},
+	"BE": {50.503887, 4.469936}, "BG": {42.733883, 25.48583}, "BH": {25.930414, 50.637772},
+	"BR": {-14.235004, -51.92528}, "BY": {53.709807, 27.953389}, "CA": {56.130366, -106.346771},
+	"CH": {46.818188, 8.227512}, "CL": {-35.675147, -71.542969}, "CN": {35.86166, 104.195397},
+	"CO": {4.570868, -74.297333}, "CR": {9.748917, -83.753428}, "CY": {35.126413, 33.429859},
+	"CZ": {49.817492, 15.472962}, "DE": {51.165691, 10.451526}, "DK": {56.26392, 9.501785},
+	"DZ": {28.033886, 1.659626}, "EC": {-1.831239, -78.183406}, "EE": {58.595272, 25.013607},
+	"EG": {26.820553, 30.802498}, "ES": {40.463667, -3.74922}, "FI": {61.92411, 25.748151},
+	"FR": {46.227638, 2.213749}, "GB": {55.378051, -3.435973}, "GE": {42.315407, 43.356892},
+	"GH": {7.946527, -1.023194}, "GR": {39.074208, 21.824312}, "HK": {22.396428, 114.109497},
+	"HR": {45.1, 15.2}, "HU": {47.162494, 19.503304}, "ID": {-0.789275, 113.921327},
+	"IE": {53.41291, -8.24389}, "IL": {31.046051, 34.851612}, "IN": {20.593684, 78.96288},
+	"IQ": {33.223191, 43.679291}, "IR": {32.427908, 53.688046}, "IS": {64.963051, -19.020835},
+	"IT": {41.87194, 12.56738}, "JP": {36.204824, 138.252924}, "KE": {-0.023559, 37.906193},
+	"KR": {35.907757, 127.766922}, "KZ": {48.019573, 66.923684}, "LT": {55.169438, 23.881275},
+	"LU": {49.815273, 6.129583}, "LV": {56.879635, 24.603189}, "MA": {31.791702, -7.09262},
+	"MD": {47.411631, 28.369885}, "MX": {23.634501, -102.552784}, "MY": {4.210484, 101.975766},
+	"NG": {9.081999, 8.675277}, "NL": {52.132633, 5.291266}, "NO": {60.472024, 8.468946},
+	"NZ": {-40.900557, 174.885971}, "PA": {8.537981, -80.782127}, "PE": {-9.189967, -75.015152},
+	"PH": {12.879721, 121.774017}, "PK": {30.375321, 69.345116}, "PL": {51.919438, 19.145136},
+	"PT": {39.399872, -8.224454}, "QA": {25.354826, 51.183884}, "RO": {45.943161, 24.96676},
+	"RS": {44.016521, 21.005859}, "RU": {61.52401, 105.318756}, "SA": {23.885942, 45.079162},
+	"SE": {60.128161, 18.643501}, "SG": {1.352083, 103.819836}, "SI": {46.151241, 14.995463},
+	"SK": {48.669026, 19.699024}, "TH": {15.870032, 100.992541}, "TR": {38.963745, 35.243322},
+	"TW": {23.69781, 120.960515}, "UA": {48.379433, 31.16558}, "US": {37.09024, -95.712891},
+	"UY": {-32.522779, -55.765835}, "VE": {6.42375, -66.58973}, "VN": {14.058324, 108.277199},
+	"ZA": {-30.559482, 22.937506},
+}
+
+// geoFeature is a point feature of -output geojson
+type geoFeature struct {
+	Type       string          `json:"type"`
+	Geometry   geoPoint        `json:"geometry"`
+	Properties json.RawMessage `json:"properties"`
+}
+
+// geoPoint is a GeoJSON point, whose coordinates are longitude then latitude
+type geoPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// geojsonWriter places the rows of the results on a map as a GeoJSON feature
+// collection, a point per row at the centroid of its country. The properties
+// of a feature are the JSON of its row. Rows without a country, or with one
+// missing from countryCentroids, are skipped. The collection is one document,
+// so it is written on Close.
+type geojsonWriter struct {
+	w        io.Writer
+	features []geoFeature
+}
+
+// newGeoJSONWriter returns a geojson writer to w
+func newGeoJSONWriter(w io.Writer) *geojsonWriter {
+	return &geojsonWriter{w: w, features: []geoFeature{}}
+}
+
+// Write implements resultWriter
+func (g *geojsonWriter) Write(_ query, data interface{}) error {
+	for _, row := range locatedRows(reflect.ValueOf(data)) {
+		centroid, ok := countryCentroids[strings.ToUpper(row.country)]
+		if !ok {
+			continue
+		}
+		properties, err := encodeJSON(row.value.Interface())
+		if err != nil {
+			return err
+		}
+		g.features = append(g.features, geoFeature{
+			Type:       "Feature",
+			Geometry:   geoPoint{Type: "Point", Coordinates: [2]float64{centroid[1], centroid[0]}},
+			Properties: properties,
+		})
+	}
+	return nil
+}
+
+// Close implements resultWriter
+func (g *geojsonWriter) Close() error {
+	jsonData, err := json.Marshal(struct {
+		Type     string       `json:"type"`
+		Features []geoFeature `json:"features"`
+	}{"FeatureCollection", g.features})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(g.w, string(jsonData))
+	return err
+}
+
+// locatedRow is a row of a result with the country it is placed at
+type locatedRow struct {
+	value   reflect.Value
+	country string
+}
+
+// locatedRows returns the rows of a result that have a country field: the
+// elements of a list of rows, or of the lists a result holds, such as the
+// announcements of an IP
+func locatedRows(v reflect.Value) []locatedRow {
+	var rows []locatedRow
+	switch {
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct:
+		for i := 0; i < v.Len(); i++ {
+			if country := countryField(v.Index(i)); country != "" {
+				rows = append(rows, locatedRow{value: v.Index(i), country: country})
+			}
+		}
+	case v.Kind() == reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if field := v.Field(i); field.Kind() == reflect.Slice {
+				rows = append(rows, locatedRows(field)...)
+			}
+		}
+	}
+	return rows
+}
+
+// countryField returns the value of the country field of a row, or an empty
+// string when it has none
+func countryField(row reflect.Value) string {
+	for i := 0; i < row.NumField(); i++ {
+		if jsonName(row.Type().Field(i)) == "country" && row.Field(i).Kind() == reflect.String {
+			return row.Field(i).String()
+		}
+	}
+	return ""
+}
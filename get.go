@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// getWriter prints only the values of one field of each result, one per
+// line, for capturing in shell scripts
+type getWriter struct {
+	w     io.Writer
+	field string
+}
+
+// Write implements resultWriter
+func (g getWriter) Write(_ query, data interface{}) error {
+	// skipped and failed queries have no values to print
+	if _, ok := data.(queryStatus); ok {
+		return nil
+	}
+	v := reflect.ValueOf(data)
+	names := fieldNames(v.Type())
+	if !names[g.field] {
+		valid := make([]string, 0, len(names))
+		for name := range names {
+			valid = append(valid, name)
+		}
+		sort.Strings(valid)
+		return fmt.Errorf("unknown -get field %q, valid fields: %s",
+			g.field, strings.Join(valid, ", "))
+	}
+
+	for _, value := range fieldValues(v, g.field) {
+		if _, err := fmt.Fprintln(g.w, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close implements resultWriter
+func (g getWriter) Close() error {
+	return nil
+}
+
+// jsonName returns the JSON name of a struct field
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// nested reports whether values of the type hold rows of their own
+func nested(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct:
+		return true
+	case reflect.Slice, reflect.Map:
+		return nested(t.Elem())
+	}
+	return false
+}
+
+// fieldNames returns the JSON names of the fields in results of the type,
+// including those of the rows it holds
+func fieldNames(t reflect.Type) map[string]bool {
+	names := map[string]bool{}
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map:
+		return fieldNames(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			names[jsonName(f)] = true
+			if nested(f.Type) {
+				for name := range fieldNames(f.Type) {
+					names[name] = true
+				}
+			}
+		}
+	}
+	return names
+}
+
+// fieldValues returns the values of the named field in a result. A field of
+// the result itself is preferred over the fields of the rows it holds, so
+// -get ip on an IP result prints the address rather than its DNS records.
+func fieldValues(v reflect.Value, name string) []string {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return fieldValues(v.Elem(), name)
+	case reflect.Slice:
+		var values []string
+		for i := 0; i < v.Len(); i++ {
+			values = append(values, fieldValues(v.Index(i), name)...)
+		}
+		return values
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if jsonName(t.Field(i)) != name {
+				continue
+			}
+			// a list of values, such as the prefixes of -acl, prints
+			// a value per line
+			if field := v.Field(i); field.Kind() == reflect.Slice && !nested(field.Type()) {
+				values := make([]string, field.Len())
+				for j := range values {
+					values[j] = formatValue(field.Index(j))
+				}
+				return values
+			}
+			return []string{formatValue(v.Field(i))}
+		}
+		var values []string
+		for i := 0; i < t.NumField(); i++ {
+			if nested(t.Field(i).Type) {
+				values = append(values, fieldValues(v.Field(i), name)...)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// formatValue returns the plain text form of a field value. Unset optional
+// values print as an empty line so each row keeps its own line.
+func formatValue(v reflect.Value) string {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	return fmt.Sprint(v.Interface())
+}
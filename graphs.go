@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// GraphInfo represents the graph data an ASN page links to. Data holds the
+// parsed JSON served by the links, keyed by URL, with -include-graph-json.
+type GraphInfo struct {
+	ASN    string                     `json:"asn"`
+	Graphs []string                   `json:"graphs"`
+	Data   map[string]json.RawMessage `json:"data,omitempty"`
+}
+
+// queryGraphs collects the links and images of an ASN page that point to its
+// graph data, such as the prefix growth images, as absolute URLs in page
+// order without duplicates
+func queryGraphs(doc *goquery.Document, q query) GraphInfo {
+	info := GraphInfo{ASN: strings.ToUpper(q.Value), Graphs: []string{}}
+	base, _ := url.Parse(BaseURL + "/")
+
+	seen := map[string]bool{}
+	doc.Find("a[href*='graph'], img[src*='graph']").Each(func(i int, s *goquery.Selection) {
+		ref, ok := s.Attr("href")
+		if !ok {
+			ref, _ = s.Attr("src")
+		}
+		u, err := base.Parse(strings.TrimSpace(ref))
+		if err != nil || u.Scheme != "http" && u.Scheme != "https" {
+			return
+		}
+		if link := u.String(); !seen[link] {
+			seen[link] = true
+			info.Graphs = append(info.Graphs, link)
+		}
+	})
+
+	return info
+}
+
+// includeGraphData fetches the graph links for -include-graph-json and
+// embeds the ones serving JSON. Images and other data that is not JSON are
+// skipped, as are links that fail, with a log line each.
+func includeGraphData(ctx context.Context, info GraphInfo) GraphInfo {
+	for _, link := range info.Graphs {
+		data, err := fetchJSON(ctx, link)
+		if err != nil {
+			log.Printf("-include-graph-json: %s: %v", link, err)
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		if info.Data == nil {
+			info.Data = map[string]json.RawMessage{}
+		}
+		info.Data[link] = data
+	}
+	return info
+}
+
+// fetchJSON fetches a URL that should serve JSON, within the rate and
+// per-host limits of the run, and returns the document. It fails on an error
+// status, a content type other than JSON or a body that does not parse.
+func fetchJSON(ctx context.Context, link string) (json.RawMessage, error) {
+	req, err := newRequest(ctx, http.MethodGet, link)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	release, err := hostSlots.acquire(ctx, req.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if err := limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	reqCtx, cancel := requestContext(ctx)
+	defer cancel()
+	res, err := client.Do(req.WithContext(reqCtx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", res.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return nil, fmt.Errorf("not JSON but %q", mediaType)
+	}
+
+	body, err := readBody(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("invalid JSON")
+	}
+	return body, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// TestGraphDataCredentials checks that the -header, -cookie and -user-agent
+// values only go to the site, not to the other hosts graph links point to
+func TestGraphDataCredentials(t *testing.T) {
+	var mu sync.Mutex
+	seen := map[string]http.Header{}
+	record := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			seen[name+r.URL.Path] = r.Header.Clone()
+			mu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"points":[1,2]}`))
+		}
+	}
+	other := httptest.NewServer(record("other"))
+	defer other.Close()
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved" {
+			mu.Lock()
+			seen["site/moved"] = r.Header.Clone()
+			mu.Unlock()
+			http.Redirect(w, r, other.URL+"/moved", http.StatusFound)
+			return
+		}
+		record("site")(w, r)
+	}))
+	defer site.Close()
+
+	defer func(saved options, savedClient *http.Client) { opts, client = saved, savedClient }(opts, client)
+	opts = options{baseURL: listValue{site.URL}, cookie: "session=abc", userAgent: "hebgp-test"}
+	if err := opts.headers.Set("X-Api-Key: secret"); err != nil {
+		t.Fatal(err)
+	}
+	client = &http.Client{CheckRedirect: checkRedirect}
+
+	info := includeGraphData(context.Background(), GraphInfo{Graphs: []string{
+		site.URL + "/graph.json", other.URL + "/graph.json", site.URL + "/moved",
+	}})
+	if len(info.Data) != 3 {
+		t.Fatalf("got the data of %d links, want 3", len(info.Data))
+	}
+
+	tests := []struct {
+		request     string
+		credentials bool
+	}{
+		{"site/graph.json", true},
+		{"other/graph.json", false},
+		{"site/moved", true},
+		// the redirect from the site to the other host
+		{"other/moved", false},
+	}
+	for _, tt := range tests {
+		h, ok := seen[tt.request]
+		if !ok {
+			t.Errorf("%s: no request", tt.request)
+			continue
+		}
+		got := map[string]string{"X-Api-Key": h.Get("X-Api-Key"), "Cookie": h.Get("Cookie")}
+		if tt.credentials {
+			if got["X-Api-Key"] != "secret" || got["Cookie"] != "session=abc" || h.Get("User-Agent") != "hebgp-test" {
+				t.Errorf("%s: got headers %v, want the credentials", tt.request, h)
+			}
+			continue
+		}
+		if got["X-Api-Key"] != "" || got["Cookie"] != "" || h.Get("User-Agent") == "hebgp-test" {
+			t.Errorf("%s: got headers %v, want no credentials", tt.request, h)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// PrefixEvent is an announcement or withdrawal of a prefix in the routing
+// history of an ASN or network block page. Time is in RFC 3339 when it
+// parses, and kept as shown otherwise.
+type PrefixEvent struct {
+	Time      string `json:"time"`
+	Event     string `json:"event"`
+	Prefix    string `json:"prefix"`
+	ASN       string `json:"asn,omitempty"`
+	Malformed bool   `json:"malformed,omitempty"`
+}
+
+// historyRows selects the rows of the history table of a page
+const historyRows = "#history tbody tr, #table_history tbody tr"
+
+// eventLayouts are the layouts the times of history events are written in,
+// tried in order once any zone abbreviation is cut off
+var eventLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2 Jan 2006 15:04:05",
+	"2 Jan 2006 15:04",
+	"2 Jan 2006",
+}
+
+// zoneSuffix matches a zone abbreviation ending a time, one of zoneOffsets
+var zoneSuffix = regexp.MustCompile(`\s+([A-Za-z]{1,5})$`)
+
+// parseEventTime parses the time of a history event. A time without a zone
+// is taken as UTC, like the update time of a page.
+func parseEventTime(text string) (time.Time, bool) {
+	text = strings.Join(strings.Fields(text), " ")
+	zone := time.UTC
+	if m := zoneSuffix.FindStringSubmatch(text); m != nil {
+		offset, ok := zoneOffsets[strings.ToUpper(m[1])]
+		if !ok {
+			return time.Time{}, false
+		}
+		zone = time.FixedZone(strings.ToUpper(m[1]), offset*3600)
+		text = strings.TrimSuffix(text, m[0])
+	}
+	for _, layout := range eventLayouts {
+		if t, err := time.ParseInLocation(layout, text, zone); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// queryHistory lists the announcements and withdrawals of the history table
+// of an ASN or network block page, in page order. The columns go by header,
+// falling back on time, event, prefix and ASN in that order.
+func queryHistory(doc *goquery.Document, _ query) []PrefixEvent {
+	events := []PrefixEvent{}
+	doc.Find(historyRows).Each(func(i int, row *goquery.Selection) {
+		event := PrefixEvent{
+			Time:      cellText(row, 0, "time", "date"),
+			Event:     strings.ToLower(cellText(row, 1, "event", "action")),
+			Prefix:    prefixKey(cellText(row, 2, "prefix", "network")),
+			ASN:       strings.ToUpper(cellText(row, 3, "asn", "origin")),
+			Malformed: shortRow(row, 3),
+		}
+		if t, ok := parseEventTime(event.Time); ok {
+			event.Time = t.Format(time.RFC3339)
+		}
+		events = append(events, event)
+	})
+	return events
+}
+
+// daysPattern matches a -since duration in days, which time.ParseDuration
+// does not take
+var daysPattern = regexp.MustCompile(`^(\d+)d$`)
+
+// sinceTime returns the time -since keeps the events after: now less a
+// duration such as 72h or 7d, or a date and time written like those of the
+// events
+func sinceTime(since string, now time.Time) (time.Time, error) {
+	if m := daysPattern.FindStringSubmatch(since); m != nil {
+		days, err := strconv.Atoi(m[1])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("-since %q: %w", since, err)
+		}
+		return now.AddDate(0, 0, -days), nil
+	}
+	if d, err := time.ParseDuration(since); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, ok := parseEventTime(since); ok {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("-since %q is neither a duration nor a date", since)
+}
+
+// filterEvents keeps the events after the -since time. Events whose time
+// does not parse cannot be placed and are dropped, counted in a warning.
+func filterEvents(events []PrefixEvent, q query, since time.Time) []PrefixEvent {
+	kept := []PrefixEvent{}
+	unparsed := 0
+	for _, event := range events {
+		t, ok := parseEventTime(event.Time)
+		if !ok {
+			unparsed++
+			continue
+		}
+		if t.After(since) {
+			kept = append(kept, event)
+		}
+	}
+	if unparsed > 0 {
+		log.Printf("%s %s: -since dropped %d events without a parseable time", q.Type, q.Value, unparsed)
+	}
+	return kept
+}
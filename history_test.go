@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueryHistory(t *testing.T) {
+	doc := loadFixture(t, "asn-history.html")
+	want := []PrefixEvent{
+		{Time: "2026-09-28T22:10:05Z", Event: "announced", Prefix: "192.0.2.0/24", ASN: "AS64500"},
+		{Time: "2026-09-30T09:15:00-08:00", Event: "withdrawn", Prefix: "198.51.100.0/24", ASN: "AS64500"},
+		{Time: "2026-10-02T06:00:00Z", Event: "announced", Prefix: "2001:db8::/32", ASN: "AS64500"},
+		{Time: "2026-10-12T00:00:00Z", Event: "announced", Prefix: "203.0.113.0/24", ASN: "AS64500"},
+		{Time: "recently", Event: "withdrawn", Prefix: "192.0.2.0/25", ASN: "AS64500"},
+	}
+	checkResult(t, queryHistory(doc, query{Type: "asn", Value: "AS64500"}), want)
+}
+
+func TestFilterEventsSince(t *testing.T) {
+	doc := loadFixture(t, "asn-history.html")
+	events := queryHistory(doc, query{Type: "asn", Value: "AS64500"})
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		since string
+		want  []string
+	}{
+		// the event without a parseable time is always dropped
+		{"2026-10-01", []string{"2001:db8::/32", "203.0.113.0/24"}},
+		{"16d", []string{"192.0.2.0/24", "198.51.100.0/24", "2001:db8::/32", "203.0.113.0/24"}},
+		{"72h", []string{"203.0.113.0/24"}},
+		{"30 Sep 2026 17:00 UTC", []string{"198.51.100.0/24", "2001:db8::/32", "203.0.113.0/24"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.since, func(t *testing.T) {
+			since, err := sinceTime(tt.since, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, event := range filterEvents(events, query{Type: "asn", Value: "AS64500"}, since) {
+				got = append(got, event.Prefix)
+			}
+			checkResult(t, got, tt.want)
+		})
+	}
+
+	if _, err := sinceTime("last week", now); err == nil {
+		t.Error("-since last week accepted")
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// maxCellWidth caps the width of a column of the interactive table, longer
+// values are cut
+const maxCellWidth = 40
+
+// resultTable holds rows of results with the same columns
+type resultTable struct {
+	header []string
+	rows   [][]string
+}
+
+// interactiveWriter collects the results of the run and shows them in a
+// paged table once every query is done, one table per kind of result
+type interactiveWriter struct {
+	tables []*resultTable
+}
+
+// Write implements resultWriter
+func (iw *interactiveWriter) Write(q query, data interface{}) error {
+	header, rows := tableOf(data)
+	header = append([]string{"query"}, header...)
+	for i, row := range rows {
+		rows[i] = append([]string{q.Value}, row...)
+	}
+
+	key := strings.Join(header, "\t")
+	for _, t := range iw.tables {
+		if strings.Join(t.header, "\t") == key {
+			t.rows = append(t.rows, rows...)
+			return nil
+		}
+	}
+	iw.tables = append(iw.tables, &resultTable{header: header, rows: rows})
+	return nil
+}
+
+// Close implements resultWriter, showing the collected tables in turn
+func (iw *interactiveWriter) Close() error {
+	for _, t := range iw.tables {
+		quit, err := showTable(t)
+		if err != nil || quit {
+			return err
+		}
+	}
+	return nil
+}
+
+// scalarField reports whether a field holds a single value rather than rows
+func scalarField(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Map:
+		return false
+	}
+	return true
+}
+
+// scalarColumns returns the JSON names and indexes of the single value fields
+// of a struct type
+func scalarColumns(t reflect.Type) ([]string, []int) {
+	var names []string
+	var index []int
+	for i := 0; i < t.NumField(); i++ {
+		if scalarField(t.Field(i).Type) {
+			names = append(names, jsonName(t.Field(i)))
+			index = append(index, i)
+		}
+	}
+	return names, index
+}
+
+// tableOf lays a result out as a table. A list of rows, or the first list of
+// rows in a result such as the announcements of an IP, gives one line per row.
+// A list of values, such as the links of -graphs, gives one line per value
+// next to the other fields. Any other result is a single line.
+func tableOf(data interface{}) ([]string, [][]string) {
+	v := reflect.ValueOf(data)
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct {
+		return structRows(v)
+	}
+	if v.Kind() != reflect.Struct {
+		return []string{"value"}, [][]string{{fmt.Sprint(data)}}
+	}
+
+	names, index := scalarColumns(v.Type())
+	row := make([]string, len(index))
+	for i, field := range index {
+		row[i] = formatValue(v.Field(field))
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() != reflect.Slice {
+			continue
+		}
+		if field.Type().Elem().Kind() == reflect.Struct {
+			return structRows(field)
+		}
+
+		header := append(names, jsonName(v.Type().Field(i)))
+		var rows [][]string
+		for j := 0; j < field.Len(); j++ {
+			rows = append(rows, append(append([]string{}, row...), formatValue(field.Index(j))))
+		}
+		return header, rows
+	}
+	return names, [][]string{row}
+}
+
+// structRows returns the single value fields of a list of structs as rows
+func structRows(v reflect.Value) ([]string, [][]string) {
+	names, index := scalarColumns(v.Type().Elem())
+	var rows [][]string
+	for i := 0; i < v.Len(); i++ {
+		row := make([]string, len(index))
+		for j, field := range index {
+			row[j] = formatValue(v.Index(i).Field(field))
+		}
+		rows = append(rows, row)
+	}
+	return names, rows
+}
+
+// fitCell pads or cuts a cell to the given width
+func fitCell(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n <= width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	if width < 1 {
+		return ""
+	}
+	return string([]rune(s)[:width-1]) + "…"
+}
+
+// tableView is the state of a table shown by showTable
+type tableView struct {
+	t       *resultTable
+	offset  int
+	sortCol int // -1 keeps the order the rows were fetched in
+	desc    bool
+}
+
+// sortRows orders the rows by the sort column
+func (tv *tableView) sortRows() {
+	if tv.sortCol < 0 {
+		return
+	}
+	col := tv.sortCol
+	cells := make([]string, len(tv.t.rows))
+	for i, row := range tv.t.rows {
+		cells[i] = row[col]
+	}
+	less := columnOrder(cells)
+	sort.SliceStable(tv.t.rows, func(i, j int) bool {
+		a, b := tv.t.rows[i][col], tv.t.rows[j][col]
+		if tv.desc {
+			return less(b, a)
+		}
+		return less(a, b)
+	})
+}
+
+// render draws a page of the table sized to the terminal
+func (tv *tableView) render(width, height int) string {
+	t := tv.t
+	widths := make([]int, len(t.header))
+	for i, name := range t.header {
+		// room for the column number and the sort arrow
+		widths[i] = utf8.RuneCountInString(name) + 3
+	}
+	for _, row := range t.rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], min(utf8.RuneCountInString(cell), maxCellWidth))
+		}
+	}
+
+	line := func(cells []string) string {
+		parts := make([]string, len(cells))
+		for i, cell := range cells {
+			parts[i] = fitCell(cell, widths[i])
+		}
+		s := strings.Join(parts, "  ")
+		if utf8.RuneCountInString(s) > width {
+			s = string([]rune(s)[:width])
+		}
+		return s + "\r\n"
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+
+	header := make([]string, len(t.header))
+	for i, name := range t.header {
+		header[i] = fmt.Sprintf("%d:%s", i+1, name)
+		if i == tv.sortCol {
+			header[i] += map[bool]string{false: "↑", true: "↓"}[tv.desc]
+		}
+	}
+	b.WriteString("\x1b[1m" + line(header) + "\x1b[0m")
+
+	page := max(height-2, 1)
+	end := min(tv.offset+page, len(t.rows))
+	for _, row := range t.rows[tv.offset:end] {
+		b.WriteString(line(row))
+	}
+
+	fmt.Fprintf(&b, "\x1b[%d;1H\x1b[7m%s\x1b[0m", height, fitCell(fmt.Sprintf(
+		" rows %d-%d of %d | j/k scroll, space/b page, 1-9 sort, q quit",
+		min(tv.offset+1, end), end, len(t.rows)), width))
+	return b.String()
+}
+
+// showTable pages through a table in the terminal until q, which moves on to
+// the next table, or Ctrl-C, which reports quit. Keys are read from the
+// terminal itself, so it also works when the batch came from stdin.
+func showTable(t *resultTable) (bool, error) {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return false, fmt.Errorf("-interactive: %w", err)
+	}
+	defer tty.Close()
+
+	state, err := term.MakeRaw(int(tty.Fd()))
+	if err != nil {
+		return false, fmt.Errorf("-interactive: %w", err)
+	}
+	defer term.Restore(int(tty.Fd()), state)
+
+	// draw on the alternate screen, leaving the scrollback as it was
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	tv := &tableView{t: t, sortCol: -1}
+	key := make([]byte, 8)
+	for {
+		width, height, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil || width <= 0 || height <= 0 {
+			width, height = 80, 24
+		}
+		page := max(height-2, 1)
+		last := max(len(t.rows)-page, 0)
+		tv.offset = min(max(tv.offset, 0), last)
+		fmt.Print(tv.render(width, height))
+
+		n, err := tty.Read(key)
+		if err != nil {
+			return false, err
+		}
+		switch k := string(key[:n]); {
+		case k == "q":
+			return false, nil
+		case k == "\x03":
+			return true, nil
+		case k == "j" || k == "\x1b[B":
+			tv.offset++
+		case k == "k" || k == "\x1b[A":
+			tv.offset--
+		case k == " " || k == "\x1b[6~":
+			tv.offset += page
+		case k == "b" || k == "\x1b[5~":
+			tv.offset -= page
+		case k == "g" || k == "\x1b[H":
+			tv.offset = 0
+		case k == "G" || k == "\x1b[F":
+			tv.offset = last
+		case len(k) == 1 && k[0] >= '1' && k[0] <= '9':
+			col := int(k[0] - '1')
+			if col >= len(t.header) {
+				continue
+			}
+			tv.desc = col == tv.sortCol && !tv.desc
+			tv.sortCol = col
+			tv.sortRows()
+			tv.offset = 0
+		}
+	}
+}
+
+// newInteractiveWriter returns the writer of -interactive, which needs stdout
+// to be a terminal
+func newInteractiveWriter() (resultWriter, error) {
+	if opts.outFile != "" {
+		return nil, errors.New("-interactive cannot be combined with -o")
+	}
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		return nil, errors.New("-interactive needs a terminal")
+	}
+	return &interactiveWriter{}, nil
+}
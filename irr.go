@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// IRRDiff compares the prefixes an ASN announces with those registered for
+// it in the IRR. Both lists are null when the page shows no IRR data, which
+// Note then explains, rather than looking like a clean diff.
+type IRRDiff struct {
+	ASN          string   `json:"asn"`
+	Available    bool     `json:"available"`
+	Unregistered []string `json:"announced_not_registered"`
+	Unannounced  []string `json:"registered_not_announced"`
+	Note         string   `json:"note,omitempty"`
+}
+
+// prefixSet returns the prefixes in the first column of the rows, or the
+// column headed prefix, normalized so that differently written prefixes match
+func prefixSet(rows *goquery.Selection) map[string]bool {
+	set := map[string]bool{}
+	rows.Each(func(i int, row *goquery.Selection) {
+		prefix := cellText(row, 0, "prefix", "route")
+		if _, ipnet, err := net.ParseCIDR(prefix); err == nil {
+			prefix = ipnet.String()
+		}
+		if prefix != "" {
+			set[prefix] = true
+		}
+	})
+	return set
+}
+
+// missing returns the prefixes of a that are not in b, sorted
+func missing(a, b map[string]bool) []string {
+	keys := []string{}
+	for prefix := range a {
+		if !b[prefix] {
+			keys = append(keys, prefix)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// queryIRR diffs the IPv4 and IPv6 prefixes announced by an ASN against the
+// route objects listed in the IRR section of its page
+func queryIRR(doc *goquery.Document, q query) IRRDiff {
+	diff := IRRDiff{ASN: strings.ToUpper(q.Value)}
+	irr := doc.Find("#irr tbody tr, #table_irr tbody tr")
+	if irr.Length() == 0 {
+		diff.Note = "the page shows no IRR data for this ASN"
+		return diff
+	}
+
+	announced := prefixSet(matchedPrefixTables(doc).Find("tbody tr"))
+	registered := prefixSet(irr)
+	diff.Available = true
+	diff.Unregistered = missing(announced, registered)
+	diff.Unannounced = missing(registered, announced)
+	return diff
+}
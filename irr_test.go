@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestQueryIRR(t *testing.T) {
+	defer func(saved options) { opts = saved }(opts)
+	tests := []struct {
+		file       string
+		categories listValue
+		want       IRRDiff
+	}{
+		{"asn-irr.html", nil, IRRDiff{ASN: "AS64500", Available: true,
+			Unregistered: []string{"192.0.2.0/24"}, Unannounced: []string{"203.0.113.0/24"}}},
+		// the page has no transit prefixes, so every route object is unannounced
+		{"asn-irr.html", listValue{"transit"}, IRRDiff{ASN: "AS64500", Available: true,
+			Unregistered: []string{}, Unannounced: []string{"198.51.100.0/24", "2001:db8::/32", "203.0.113.0/24"}}},
+		{"asn-rpki.html", nil, IRRDiff{ASN: "AS64500", Note: "the page shows no IRR data for this ASN"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %v", tt.file, tt.categories), func(t *testing.T) {
+			opts = options{prefixCategory: tt.categories}
+			checkResult(t, queryIRR(loadFixture(t, tt.file), query{Type: "asn", Value: "as64500"}), tt.want)
+		})
+	}
+}
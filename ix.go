@@ -0,0 +1,61 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// IXInfo represents an exchange an ASN is present at
+type IXInfo struct {
+	IXName    string `json:"ix_name"`
+	Location  string `json:"location"`
+	IPv4      string `json:"ipv4"`
+	IPv6      string `json:"ipv6"`
+	URL       string `json:"url,omitempty"`
+	Malformed bool   `json:"malformed,omitempty"`
+}
+
+// cellText returns the trimmed text of the row's cell at the column whose
+// header contains one of the names, or of the fallback column when the table
+// has no such header. A negative fallback yields an empty string.
+func cellText(row *goquery.Selection, fallback int, names ...string) string {
+	col := headerIndex(row, names...)
+	if col < 0 {
+		col = fallback
+	}
+	if col < 0 {
+		return ""
+	}
+	return strings.Join(strings.Fields(row.Find("td").Eq(col).Text()), " ")
+}
+
+// queryIX query the exchanges table of an ASN page and return one record per
+// exchange. An ASN present at no exchange yields an empty list.
+func queryIX(doc *goquery.Document) interface{} {
+	rows := []IXInfo{}
+
+	doc.Find("#ix tbody tr, #exchanges tbody tr").Each(func(i int,
+		row *goquery.Selection) {
+		name := cellText(row, 0, "ix", "exchange")
+		city := cellText(row, 1, "city", "location")
+		country := cellText(row, -1, "country")
+
+		location := city
+		if country != "" && country != city {
+			location = strings.TrimPrefix(city+", "+country, ", ")
+		}
+
+		res := IXInfo{IXName: name, Location: location,
+			IPv4: cellText(row, 2, "ipv4"), IPv6: cellText(row, 3, "ipv6"),
+			Malformed: shortRow(row, 4), URL: rowURL(row, 0, []string{"ix", "exchange"})}
+		rows = append(rows, res)
+	})
+
+	kept, malformed := dropMalformed(rows)
+	if malformed > 0 {
+		log.Printf("ix: %d malformed rows", malformed)
+	}
+	return kept
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// keyedWriter prints the results of the run as one JSON object keyed by the
+// input value of each query, as given before any normalization like with
+// -echo-query, for looking results up by target. A value given again is
+// keyed with a #2, #3... suffix, keeping every result as it is. The keys
+// keep the order the results come in, and the object is one document, so it
+// is written on Close.
+type keyedWriter struct {
+	w    io.Writer
+	keys []string
+	vals []json.RawMessage
+	seen map[string]int
+}
+
+// newKeyedWriter returns a keyed writer to w
+func newKeyedWriter(w io.Writer) *keyedWriter {
+	return &keyedWriter{w: w, seen: map[string]int{}}
+}
+
+// Write implements resultWriter
+func (k *keyedWriter) Write(q query, data interface{}) error {
+	jsonData, err := encodeJSON(data)
+	if err != nil {
+		return err
+	}
+	key := q.Value
+	k.seen[q.Value]++
+	if n := k.seen[q.Value]; n > 1 {
+		key = fmt.Sprintf("%s#%d", q.Value, n)
+	}
+	k.keys = append(k.keys, key)
+	k.vals = append(k.vals, jsonData)
+	return nil
+}
+
+// Close implements resultWriter
+func (k *keyedWriter) Close() error {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range k.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(k.vals[i])
+	}
+	b.WriteString("}\n")
+	_, err := k.w.Write(b.Bytes())
+	return err
+}
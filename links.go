@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// rowURL returns the canonical URL on the BGP website of the entity shown in
+// a cell of the row, located like cellText. The link of the cell is followed
+// when it has one, otherwise the page is built from the segments, and an
+// empty segment yields no URL. Links of a mirror point to bgp.he.net all the
+// same, so the URLs do not depend on -base-url.
+func rowURL(row *goquery.Selection, fallback int, names []string, segments ...string) string {
+	base, _ := url.Parse(BaseURL)
+	col := headerIndex(row, names...)
+	if col < 0 {
+		col = fallback
+	}
+
+	href, ok := row.Find("td").Eq(col).Find("a[href]").First().Attr("href")
+	if ref, err := url.Parse(strings.TrimSpace(href)); ok && err == nil && ref.Path != "" {
+		u := base.ResolveReference(ref)
+		u.Scheme, u.Host = base.Scheme, base.Host
+		return u.String()
+	}
+
+	if len(segments) == 0 {
+		return ""
+	}
+	for _, segment := range segments {
+		if segment == "" {
+			return ""
+		}
+	}
+	setPath(base, segments...)
+	return base.String()
+}
+
+// netSegments returns the path segments of the page of a network block
+func netSegments(network string) []string {
+	return append([]string{"net"}, strings.Split(network, "/")...)
+}
+
+// orgSegments returns the path segments of the page of an organization search
+// result, or none for a type whose pages cannot be told from the result
+func orgSegments(o ORGInfo) []string {
+	switch o.Type {
+	case orgTypeASN:
+		return []string{strings.ToUpper(o.Result)}
+	case orgTypeNet:
+		return netSegments(o.Result)
+	case orgTypeDNS:
+		return []string{"dns", o.Hostname}
+	}
+	return nil
+}
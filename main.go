@@ -3,204 +3,1327 @@
 package main
 
 import (
-	"encoding/json"
-	"flag"
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/idna"
 )
 
-// BaseURL is the base URL of the BGP website
+// BaseURL is the base URL of the BGP website, queried unless -base-url is set
 const BaseURL = "https://bgp.he.net"
 
+// Exit codes of the program
+const (
+	exitOK      = 0 // every query succeeded
+	exitFailure = 1 // every query performed failed
+	exitUsage   = 2 // invalid command-line usage
+	exitPartial = 3 // some queries failed or were skipped, others succeeded
+	exitTimeout = 4 // the run was cut short by -max-runtime
+	exitEmpty   = 5 // every query succeeded but some found nothing
+)
+
+// errEmpty is returned for a query whose result holds no rows
+var errEmpty = errors.New("empty result")
+
 // IPInfo represents information about an IP address
 type IPInfo struct {
-	ASN         string `json:"asn"`
-	Network     string `json:"network"`
-	Description string `json:"description"`
+	ASN         string   `json:"asn"`
+	ASNName     string   `json:"asn_name,omitempty"`
+	ASNCountry  string   `json:"asn_country,omitempty"`
+	Network     string   `json:"network"`
+	Description string   `json:"description"`
+	Country     string   `json:"country"`
+	RPKI        string   `json:"rpki"`
+	Origin      string   `json:"origin,omitempty"`
+	ASPath      string   `json:"as_path,omitempty"`
+	Registry    string   `json:"registry,omitempty"`
+	URL         string   `json:"url,omitempty"`
+	MultiOrigin bool     `json:"multi_origin,omitempty"`
+	OriginASNs  []string `json:"origin_asns,omitempty"`
+	Malformed   bool     `json:"malformed,omitempty"`
+}
+
+// DNSInfo represents a DNS record shown for an IP address
+type DNSInfo struct {
+	IP        string `json:"ip"`
+	PTR       string `json:"ptr"`
+	ARecords  string `json:"a_records"`
+	URL       string `json:"url,omitempty"`
+	Malformed bool   `json:"malformed,omitempty"`
+}
+
+// IPResult represents the sections of an IP address detail page. Routed is
+// false when the page says the IP is not announced in BGP, and unset when the
+// page shows neither announcements nor such a message. Whois holds the whole
+// whois text and WhoisSources the text of each block by its source.
+type IPResult struct {
+	IP           string            `json:"ip"`
+	Routed       *bool             `json:"routed,omitempty"`
+	Announcement []IPInfo          `json:"announcement"`
+	DNS          []DNSInfo         `json:"dns"`
+	Whois        string            `json:"whois"`
+	WhoisSources map[string]string `json:"whois_sources,omitempty"`
 }
 
 // NETInfo represents information about a network block
 type NETInfo struct {
-	ASN         string `json:"asn"`
-	Network     string `json:"network"`
-	Description string `json:"description"`
+	ASN         string   `json:"asn"`
+	ASNName     string   `json:"asn_name,omitempty"`
+	ASNCountry  string   `json:"asn_country,omitempty"`
+	Network     string   `json:"network"`
+	Description string   `json:"description"`
+	Country     string   `json:"country"`
+	RPKI        string   `json:"rpki"`
+	Registry    string   `json:"registry,omitempty"`
+	URL         string   `json:"url,omitempty"`
+	MultiOrigin bool     `json:"multi_origin,omitempty"`
+	OriginASNs  []string `json:"origin_asns,omitempty"`
+	Malformed   bool     `json:"malformed,omitempty"`
 }
 
-// ASNInfo represents information about an ASN number
+// ASNInfo represents information about an ASN number. ASCountry is the
+// country the AS is registered in, shown in the page header, while Country is
+// that of the prefix. Table is the id of the prefix table the row comes from
+// and Category the kind of prefixes it lists, such as originated or transit.
 type ASNInfo struct {
 	Prefix      string `json:"prefix"`
 	Description string `json:"description"`
+	Country     string `json:"country"`
+	RPKI        string `json:"rpki"`
+	ASCountry   string `json:"as_country,omitempty"`
+	Table       string `json:"table"`
+	Category    string `json:"category"`
+	URL         string `json:"url,omitempty"`
+	Malformed   bool   `json:"malformed,omitempty"`
 }
 
-// ORGInfo represents information about an organization
+// ORGInfo represents information about an organization. Type is normalized
+// to one of the orgType values while RawType keeps the text shown on the site.
+// A DNS result also carries its hostname and the addresses it resolves to.
 type ORGInfo struct {
-	Result      string `json:"result"`
-	Type        string `json:"type"`
-	Description string `json:"description"`
+	Result      string   `json:"result"`
+	Type        string   `json:"type"`
+	RawType     string   `json:"raw_type"`
+	Description string   `json:"description"`
+	Country     string   `json:"country"`
+	Hostname    string   `json:"hostname,omitempty"`
+	Addresses   []string `json:"addresses,omitempty"`
+	URL         string   `json:"url,omitempty"`
+	Malformed   bool     `json:"malformed,omitempty"`
+}
+
+// Normalized types of organization search results
+const (
+	orgTypeASN     = "asn"
+	orgTypeNet     = "net"
+	orgTypeOrg     = "org"
+	orgTypeIX      = "ix"
+	orgTypeDNS     = "dns"
+	orgTypeUnknown = "unknown"
+)
+
+// orgTypes maps the lowercased type strings shown in search results to their
+// normalized type
+var orgTypes = map[string]string{
+	"asn":               orgTypeASN,
+	"as":                orgTypeASN,
+	"route":             orgTypeNet,
+	"network":           orgTypeNet,
+	"prefix":            orgTypeNet,
+	"ipv4":              orgTypeNet,
+	"ipv6":              orgTypeNet,
+	"org":               orgTypeOrg,
+	"organization":      orgTypeOrg,
+	"organisation":      orgTypeOrg,
+	"ix":                orgTypeIX,
+	"exchange":          orgTypeIX,
+	"internet exchange": orgTypeIX,
+	"dns":               orgTypeDNS,
+	"hostname":          orgTypeDNS,
+	"domain":            orgTypeDNS,
+}
+
+// normalizeOrgType returns the normalized type of a search result type string
+func normalizeOrgType(raw string) string {
+	if kind, ok := orgTypes[strings.ToLower(raw)]; ok {
+		return kind
+	}
+	return orgTypeUnknown
+}
+
+// query describes a single lookup to perform against the BGP website
+type query struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// notRoutedPattern matches the message an IP page shows for an address that
+// is not announced in BGP
+var notRoutedPattern = regexp.MustCompile(`(?i)not (currently )?(routed|announced)|no (bgp )?(data|route)`)
+
+// queryFuncs maps each query type to the function that parses its page
+var queryFuncs = map[string]func(*goquery.Document, query) interface{}{
+	"asn": queryASN,
+	"ip":  queryIP,
+	"net": queryNET,
+	"org": queryORG,
+
+	"find-asn": queryFindASN,
 }
 
 func main() {
-	// Initialize command-line parameters
-	getASN := flag.String("asn", "", "Query for ASN")
-	getIP := flag.String("ip", "", "Query for IP")
-	getNET := flag.String("net", "", "Query for network block")
-	getORG := flag.String("org", "", "Query for organization")
-	getHelp := flag.Bool("h", false, "Show help message")
-	flag.Parse()
+	if shell, ok := completionArg(os.Args[1:]); ok {
+		os.Exit(runCompletion(shell, filepath.Base(os.Args[0])))
+	}
+	if len(os.Args) > 1 {
+		if cmd, ok := findCommand(os.Args[1]); ok {
+			os.Exit(runCommand(cmd, os.Args[2:]))
+		}
+	}
+	os.Exit(runLegacy(os.Args[1:]))
+}
 
-	// Show help message
-	if len(os.Args[1:]) == 0 || *getHelp {
-		showHelpMessage()
+// run performs the queries in order and returns the exit code. Several
+// queries abort on the first error while a batch keeps going; -fail-fast and
+// -keep-going override either default. When the -deadline or -max-runtime
+// passes, the remaining queries are reported as skipped.
+func run(queries []query, batch bool) int {
+	if opts.failFast && opts.keepGoing {
+		log.Print("-fail-fast and -keep-going are mutually exclusive")
+		return exitUsage
+	}
+	if err := checkModes(); err != nil {
+		log.Print(err)
+		return exitUsage
+	}
+	if err := checkPrefixLens(); err != nil {
+		log.Print(err)
+		return exitUsage
 	}
 
-	// Query for ASN information
-	if *getASN != "" {
-		url := fmt.Sprintf("%s/%s", BaseURL, *getASN)
-		queryAndPrint(url, queryASN)
+	stopOnError := !batch
+	if opts.failFast {
+		stopOnError = true
 	}
+	if opts.keepGoing {
+		stopOnError = false
+	}
+
+	// -max-runtime caps the whole run, -deadline only the queries
+	runtimeCtx := context.Background()
+	if opts.maxRuntime > 0 {
+		var cancel context.CancelFunc
+		runtimeCtx, cancel = context.WithTimeout(runtimeCtx, opts.maxRuntime)
+		defer cancel()
+	}
+	ctx := runtimeCtx
+	if opts.deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.deadline)
+		defer cancel()
+	}
+
+	closeOutput, err := openOutput()
+	if err != nil {
+		log.Print(err)
+		return exitUsage
+	}
+	defer func() {
+		if err := closeOutput(); err != nil {
+			log.Print(err)
+		}
+	}()
 
-	// Query for IP information
-	if *getIP != "" {
-		url := fmt.Sprintf("%s/ip/%s", BaseURL, *getIP)
-		queryAndPrint(url, queryIP)
+	// -validate-only stops before anything is fetched
+	if opts.validateOnly {
+		invalid := validateQueries(queries)
+		log.Printf("-validate-only: %d of %d targets invalid", invalid, len(queries))
+		if invalid > 0 {
+			return exitFailure
+		}
+		return exitOK
 	}
 
-	// Query for network block information
-	if *getNET != "" {
-		url := fmt.Sprintf("%s/net/%s", BaseURL, *getNET)
-		queryAndPrint(url, queryNET)
+	if opts.diff != "" {
+		previousResults, err = loadPrevious(opts.diff)
+		if err != nil {
+			log.Print(err)
+			return exitFailure
+		}
+		// without their query, the saved results of a batch can't be told
+		// apart once a query fails or is skipped
+		if len(queries) > 1 && len(previousResults.bare) > 0 {
+			log.Printf("-diff: %s holds results without their query, save the results of several queries with -echo-query", opts.diff)
+			return exitUsage
+		}
 	}
 
-	// Query for organization information
-	if *getORG != "" {
-		url := fmt.Sprintf("%s/search?search[search]=%s&commit=Search",
-			BaseURL, *getORG)
-		queryAndPrint(url, queryORG)
+	var done *checkpoint
+	if opts.checkpoint != "" {
+		done, err = loadCheckpoint(opts.checkpoint)
+		if err != nil {
+			log.Print(err)
+			return exitFailure
+		}
+		defer func() {
+			if err := done.close(); err != nil {
+				log.Printf("checkpoint: %v", err)
+			}
+		}()
+		pending := filterRows(queries, func(q query) bool { return !done.has(q) })
+		if skipped := len(queries) - len(pending); skipped > 0 {
+			log.Printf("skipping %d queries already in %s", skipped, opts.checkpoint)
+		}
+		queries = pending
 	}
+
+	if err := setupClient(); err != nil {
+		log.Print(err)
+		return exitUsage
+	}
+	if opts.preflight && opts.htmlFile == "" {
+		if err := preflight(ctx); err != nil {
+			log.Print(err)
+			return exitFailure
+		}
+	}
+
+	if err := checkOrder(); err != nil {
+		log.Print(err)
+		return exitUsage
+	}
+	next := sequentialQueries(ctx, queries)
+	if opts.parallel > 1 {
+		// the workers stop taking queries once the run stops
+		workCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		next = parallelQueries(workCtx, queries)
+	}
+
+	// handled marks the queries whose outcome is known, the others are
+	// skipped when the run stops early
+	handled := make([]bool, len(queries))
+	stopEarly := func() int {
+		var rest []query
+		for i, q := range queries {
+			if !handled[i] {
+				rest = append(rest, q)
+			}
+		}
+		if len(rest) == 0 {
+			return -1
+		}
+		if runtimeCtx.Err() != nil {
+			log.Printf("-max-runtime of %s exceeded", opts.maxRuntime)
+			skipQueries(rest)
+			return exitTimeout
+		}
+		log.Printf("-deadline of %s exceeded", opts.deadline)
+		skipQueries(rest)
+		return exitPartial
+	}
+
+	failed, empty, succeeded := 0, 0, 0
+	for {
+		i, err, ok := next()
+		if !ok {
+			break
+		}
+		q := queries[i]
+		// an empty result is only done when -allow-empty makes it a success,
+		// a transient one is queried again on resume
+		completed := err == nil || errors.Is(err, errEmpty) && opts.allowEmpty
+		if errors.Is(err, errEmpty) {
+			if !opts.allowEmpty {
+				log.Printf("%s %s: %v", q.Type, q.Value, err)
+				empty++
+			}
+			if opts.onlyErrors {
+				writeStatus(q, "empty", err)
+			}
+			err = nil
+		}
+		if completed && done != nil {
+			if err := done.add(q); err != nil {
+				log.Printf("checkpoint: %v", err)
+			}
+		}
+		if ctx.Err() != nil {
+			// a query that completed before the deadline is not skipped.
+			// With -parallel, the queries still in flight are waited for
+			// and only those cut short are skipped.
+			handled[i] = err == nil
+			if opts.parallel == 1 {
+				if code := stopEarly(); code >= 0 {
+					return code
+				}
+			} else if err != nil {
+				continue
+			}
+		}
+		handled[i] = true
+		if err != nil {
+			log.Printf("%s %s: %v", q.Type, q.Value, err)
+			if batch || opts.onlyErrors {
+				writeStatus(q, "error", err)
+			}
+			failed++
+			if stopOnError {
+				break
+			}
+			continue
+		}
+		succeeded++
+	}
+	// with -parallel, the queries not started before the deadline have no
+	// outcome at all
+	if ctx.Err() != nil && !(stopOnError && failed > 0) {
+		if code := stopEarly(); code >= 0 {
+			return code
+		}
+	}
+
+	// a run where only some queries failed still has results worth using
+	if failed > 0 && succeeded > 0 {
+		return exitPartial
+	}
+	if failed > 0 {
+		return exitFailure
+	}
+	if empty > 0 {
+		return exitEmpty
+	}
+	return exitOK
+}
+
+// queryStatus is printed in place of the result of a query that was not
+// performed or, in a batch or with -only-errors, of one that failed. With
+// -only-errors it is also printed for a query that found nothing.
+type queryStatus struct {
+	Type   string      `json:"type"`
+	Value  string      `json:"value"`
+	Status string      `json:"status"`
+	Reason string      `json:"reason,omitempty"`
+	Error  *queryError `json:"error,omitempty"`
 }
 
-// queryAndPrint takes a url and a query function that get passed to queryParser
-// for further processing.
-func queryAndPrint(url string, queryFunc func(*goquery.Document)) {
-	doc := queryParser(url)
-	queryFunc(doc)
+// queryError describes why a query failed: the kind of error, one of
+// timeout, status, size, network or query, and its message
+type queryError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// errorKind returns the kind of error a query failed with. Failures that are
+// not those of a request, such as an invalid IP address or a page failing
+// -strict-html, are of kind query.
+func errorKind(err error) string {
+	var netErr net.Error
+	var fetchErr *fetchError
+	switch {
+	case errors.Is(err, errBodyTooLarge):
+		return "size"
+	case errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, errStatus):
+		return "status"
+	case errors.As(err, &fetchErr):
+		return "network"
+	}
+	return "query"
+}
+
+// skipQueries prints the queries left over after the deadline as skipped
+func skipQueries(queries []query) {
+	log.Printf("skipping %d queries", len(queries))
+	for _, q := range queries {
+		err := output.Write(q, queryStatus{Type: q.Type, Value: q.Value, Status: "skipped"})
+		if err != nil {
+			log.Print(err)
+		}
+	}
+}
+
+// writeStatus prints the status of a query and the error explaining it. The
+// error of a failed query is also described by its kind.
+func writeStatus(q query, status string, reason error) {
+	res := queryStatus{Type: q.Type, Value: q.Value, Status: status, Reason: reason.Error()}
+	if status == "error" {
+		res.Error = &queryError{Type: errorKind(reason), Message: reason.Error()}
+	}
+	err := output.Write(q, res)
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+// queryURL returns the URL to fetch from the site at base for the given query.
+// The value is escaped as a path segment, or as the search term of an
+// organization search, so it cannot change the shape of the URL; only the
+// slash of a network block is kept.
+func queryURL(base string, q query) string {
+	u, _ := url.Parse(base)
+	switch q.Type {
+	case "asn":
+		setPath(u, q.Value)
+	case "ip":
+		setPath(u, "ip", q.Value)
+	case "net":
+		setPath(u, append([]string{"net"}, strings.Split(q.Value, "/")...)...)
+	default:
+		setPath(u, "search")
+		u.RawQuery = url.Values{
+			"search[search]": {searchTerm(q.Value)},
+			"commit":         {"Search"},
+		}.Encode()
+	}
+	return u.String()
+}
+
+// setPath appends the segments to the path of u, escaping each one. A mirror
+// may serve the site under a path of its own.
+func setPath(u *url.URL, segments ...string) {
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		escaped[i] = url.PathEscape(segment)
+	}
+	prefix := strings.TrimSuffix(u.Path, "/")
+	u.Path = prefix + "/" + strings.Join(segments, "/")
+	u.RawPath = prefix + "/" + strings.Join(escaped, "/")
+}
+
+// searchTerm returns the organization search term for a value. Names are
+// searched as shown on the site, so internationalized domain names given in
+// their punycode form are turned back into Unicode.
+func searchTerm(value string) string {
+	words := strings.Fields(value)
+	for i, word := range words {
+		if !strings.Contains(strings.ToLower(word), "xn--") {
+			continue
+		}
+		if name, err := idna.ToUnicode(word); err == nil {
+			words[i] = name
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// detectQueryType guesses the query type of a bare target: ASNs start with
+// "AS", CIDRs are network blocks, addresses are IPs and anything else is
+// searched as an organization.
+func detectQueryType(target string) string {
+	upper := strings.ToUpper(target)
+	if strings.HasPrefix(upper, "AS") {
+		if _, err := strconv.Atoi(upper[2:]); err == nil {
+			return "asn"
+		}
+	}
+	if _, _, err := net.ParseCIDR(target); err == nil {
+		return "net"
+	}
+	if net.ParseIP(target) != nil {
+		return "ip"
+	}
+	return "org"
+}
+
+// readBatch reads one target per line from the named file, or stdin when the
+// name is "-". Blank lines and lines starting with '#' are ignored. With
+// -batch-json, the file holds a JSON array of queries instead. With -expand,
+// network blocks are expanded into their host IPs.
+func readBatch(name string) ([]query, error) {
+	f := os.Stdin
+	if name != "-" {
+		var err error
+		f, err = os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+	}
+
+	if opts.batchJSON {
+		queries, err := readBatchJSON(f)
+		if err != nil || !opts.expand {
+			return queries, err
+		}
+		return expandQueries(queries)
+	}
+
+	var queries []query
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		queries = append(queries, query{Type: detectQueryType(line), Value: line})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if opts.expand {
+		return expandQueries(queries)
+	}
+	return queries, nil
+}
+
+// normalizeQuery returns q with its value in the form the site knows, failing
+// on an invalid IP address
+func normalizeQuery(q query) (query, error) {
+	if q.Type == "ip" {
+		addr := net.ParseIP(q.Value)
+		if addr == nil {
+			return q, fmt.Errorf("invalid IP address %q", q.Value)
+		}
+		// IPv6 addresses are written in many forms, the site knows one
+		q.Value = addr.String()
+	}
+	return q, nil
+}
+
+// runQuery fetches the page of a query and passes it to the query function of
+// its type for further processing, returning the filtered result.
+func runQuery(ctx context.Context, q query) (interface{}, error) {
+	ctx = withQueryTimeout(ctx, q.Type)
+	q, err := normalizeQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	if opts.abuse && (q.Type == "org" || q.Type == "find-asn") {
+		return nil, fmt.Errorf("-abuse only applies to asn, ip and net queries")
+	}
+	if opts.atIX && q.Type != "asn" {
+		return nil, fmt.Errorf("-at-ix only applies to asn queries")
+	}
+	if opts.graphs && q.Type != "asn" {
+		return nil, fmt.Errorf("-graphs only applies to asn queries")
+	}
+	if opts.graphJSON && !opts.graphs {
+		return nil, fmt.Errorf("-include-graph-json needs -graphs")
+	}
+	if opts.stats && q.Type != "asn" {
+		return nil, fmt.Errorf("-stats only applies to asn queries")
+	}
+	if opts.summaryOnly && q.Type != "asn" {
+		return nil, fmt.Errorf("-summary-only only applies to asn queries")
+	}
+	if opts.rib != "" && q.Type != "asn" {
+		return nil, fmt.Errorf("-rib only applies to asn queries")
+	}
+	if opts.irr && q.Type != "asn" {
+		return nil, fmt.Errorf("-irr only applies to asn queries")
+	}
+	if opts.acl && q.Type != "asn" {
+		return nil, fmt.Errorf("-acl only applies to asn queries")
+	}
+	if opts.totalSpace && q.Type != "asn" {
+		return nil, fmt.Errorf("-total-space only applies to asn queries")
+	}
+	if opts.peerEdges && q.Type != "asn" {
+		return nil, fmt.Errorf("-peer-edges only applies to asn queries")
+	}
+	if opts.history && q.Type != "asn" && q.Type != "net" {
+		return nil, fmt.Errorf("-history only applies to asn and net queries")
+	}
+	if opts.since != "" && !opts.history {
+		return nil, fmt.Errorf("-since needs -history")
+	}
+	var since time.Time
+	if opts.since != "" {
+		if since, err = sinceTime(opts.since, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	if opts.family != "" && !opts.acl {
+		return nil, fmt.Errorf("-family needs -acl")
+	}
+	if opts.aggregate && !opts.acl && !opts.totalSpace {
+		return nil, fmt.Errorf("-aggregate needs -acl or -total-space")
+	}
+	if _, err := aclFamily(); err != nil {
+		return nil, err
+	}
+
+	var doc *goquery.Document
+	if opts.htmlFile != "" {
+		metaFrom(ctx).File = opts.htmlFile
+		doc, err = loadHTMLFile(opts.htmlFile)
+	} else {
+		doc, err = fetchPage(ctx, q)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := selectTable(doc); err != nil {
+		return nil, err
+	}
+	metaFrom(ctx).DataUpdated = dataUpdated(doc)
+	if opts.strictHTML {
+		if err := checkStrictHTML(doc, q); err != nil {
+			return nil, err
+		}
+	}
+	if opts.output == "both" {
+		metaFrom(ctx).rawTable = queryRawTable(doc, q)
+	}
+
+	switch {
+	case opts.abuse:
+		return queryAbuse(doc, q), nil
+	case opts.atIX:
+		return queryIX(doc), nil
+	case opts.graphs:
+		info := queryGraphs(doc, q)
+		if opts.graphJSON {
+			info = includeGraphData(ctx, info)
+		}
+		return info, nil
+	case opts.stats:
+		return queryStats(doc, q), nil
+	case opts.summaryOnly:
+		return querySummary(doc, q), nil
+	case opts.irr:
+		return queryIRR(doc, q), nil
+	case opts.rib != "":
+		return queryRIB(doc, q), nil
+	case opts.acl:
+		return queryACL(doc, q), nil
+	case opts.totalSpace:
+		return querySpace(doc, q), nil
+	case opts.peerEdges:
+		return queryPeers(ctx, doc, q), nil
+	case opts.history:
+		events := queryHistory(doc, q)
+		if opts.since != "" {
+			events = filterEvents(events, q, since)
+		}
+		return events, nil
+	case opts.rawTable:
+		return queryRawTable(doc, q), nil
+	}
+	data, malformed := dropMalformed(queryFuncs[q.Type](doc, q))
+	if malformed > 0 {
+		if opts.strictHTML {
+			return nil, fmt.Errorf("strict-html: %d malformed rows", malformed)
+		}
+		log.Printf("%s %s: %d malformed rows", q.Type, q.Value, malformed)
+	}
+	if opts.resolveNames {
+		if data, err = resolveNames(ctx, data); err != nil {
+			return nil, err
+		}
+	}
+	data = filterResult(data)
+	if opts.sort != "" {
+		return sortResult(data)
+	}
+	return data, nil
+}
+
+// queryAndPrint runs a query, then validates and prints the result to out. An
+// empty result is still printed before errEmpty is returned, which run ignores
+// with -allow-empty.
+func queryAndPrint(ctx context.Context, q query, out resultWriter) error {
+	ctx, meta := withMeta(ctx)
+	data, err := runQueryRetrying(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	empty := emptyResult(data)
+
+	var failed int
+	if opts.validate || opts.validateStrict {
+		warnings := validateResult(data)
+		for _, w := range warnings {
+			log.Printf("validate: %s", w)
+		}
+		failed = len(warnings)
+	}
+
+	if opts.diff != "" {
+		d, err := diffNext(q, data)
+		if err != nil {
+			return err
+		}
+		data = d
+	}
+
+	// -only-errors leaves the printing to run, for failed and empty queries
+	if !opts.onlyErrors {
+		var err error
+		if d, ok := data.(diffResult); ok && colorDiff(out) {
+			err = printColorDiff(os.Stdout, d)
+		} else {
+			err = out.Write(q, withEnvelope(q, meta, data))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if opts.validateStrict && failed > 0 {
+		return fmt.Errorf("%d rows failed validation", failed)
+	}
+	if empty {
+		return errEmpty
+	}
+	return nil
+}
+
+// emptyResult reports whether a result found nothing: no rows, no
+// announcement of an IP, which includes an IP that is not routed, no abuse
+// contact or no graph links
+func emptyResult(data interface{}) bool {
+	switch res := data.(type) {
+	case IPResult:
+		return len(res.Announcement) == 0
+	case []NETInfo:
+		return len(res) == 0
+	case []ASNInfo:
+		return len(res) == 0
+	case []ORGInfo:
+		return len(res) == 0
+	case []IXInfo:
+		return len(res) == 0
+	case []FoundASN:
+		return len(res) == 0
+	case AbuseInfo:
+		return res.AbuseContact == nil
+	case GraphInfo:
+		return len(res.Graphs) == 0
+	case ASNStats:
+		return res.PeersV4 == nil && res.PeersV6 == nil
+	case ASNSummary:
+		return res.PrefixesV4 == 0 && res.PrefixesV6 == 0
+	case IRRDiff:
+		return !res.Available
+	case RIBDiff:
+		return res.Matched == 0 && len(res.SiteOnly) == 0 && len(res.LocalOnly) == 0
+	case PrefixList:
+		return len(res.Prefixes) == 0
+	case []PeerEdge:
+		return len(res) == 0
+	case []PrefixEvent:
+		return len(res) == 0
+	case ASNSpace:
+		return res.TotalIPv4 == 0 && res.IPv6Prefixes == 0
+	case [][]string:
+		return len(res) == 0
+	}
+	return false
+}
+
+// fetchPage fetches the page of a query from the first host of -base-url that
+// serves it. A host that cannot be reached, or is still throttling or failing
+// once its retries are used up, is given up for the next one. The host that
+// served the page goes to the meta of the query.
+func fetchPage(ctx context.Context, q query) (*goquery.Document, error) {
+	hosts := baseURLs()
+	var errs []error
+	for i, base := range hosts {
+		doc, status, err := queryParser(ctx, queryURL(base, q))
+		last := i == len(hosts)-1
+		if err == nil && (last || !retryable(status)) {
+			u, _ := url.Parse(base)
+			metaFrom(ctx).Host = u.Host
+			if status != http.StatusOK {
+				metaFrom(ctx).Status = status
+			}
+			return doc, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("%s: status %d", base, status)
+		}
+		if ctx.Err() != nil || len(hosts) == 1 {
+			return nil, err
+		}
+		errs = append(errs, err)
+		if !last {
+			log.Printf("%s failed, trying %s", base, hosts[i+1])
+		}
+	}
+	return nil, fmt.Errorf("all %d hosts failed: %w", len(hosts), errors.Join(errs...))
 }
 
 // queryParser queries a URL, parses the HTML document using goquery, and returns
-// the document for further processing.
-func queryParser(url string) *goquery.Document {
-	res, err := http.Get(url)
+// the document for further processing with the status of the response. Network
+// errors and throttled or failed responses are retried up to -retries times,
+// within the -max-total-retries budget of the run. A request that still fails,
+// or ends with a status other than 200 unless -parse-anyway is set, returns a
+// *fetchError. Pages are served from the -cache-dir cache when it holds them.
+func queryParser(ctx context.Context, url string) (*goquery.Document, int, error) {
+	meta := metaFrom(ctx)
+	meta.URL = url
+	if body, ok := cache.get(url); ok && cacheReadable(ctx) {
+		recorder.cacheHit()
+		meta.Cached = true
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+		return doc, http.StatusOK, err
+	}
 
+	start := time.Now()
+	status := 0
+	for attempt := 1; ; attempt++ {
+		doc, code, again, err := fetchDocument(ctx, url)
+		if code != 0 {
+			status = code
+		}
+		if !again || attempt > opts.retries || !retries.take() {
+			if err != nil {
+				return nil, status, &fetchError{url: url, attempts: attempt, status: status,
+					elapsed: time.Since(start), err: err}
+			}
+			if status != http.StatusOK && !opts.parseAnyway {
+				return nil, status, &fetchError{url: url, attempts: attempt, status: status,
+					elapsed: time.Since(start), err: errStatus}
+			}
+			if status != http.StatusOK {
+				log.Printf("GET %s: status %d after %s in %s, parsing the page anyway", url, status,
+					attempts(attempt), time.Since(start).Round(time.Millisecond))
+			}
+			return doc, status, nil
+		}
+
+		delay := backoff(attempt - 1)
+		log.Printf("retrying %s in %s", url, delay.Round(time.Millisecond))
+		if err := sleep(ctx, delay); err != nil {
+			return nil, status, &fetchError{url: url, attempts: attempt, status: status,
+				elapsed: time.Since(start), err: err}
+		}
+	}
+}
+
+// fetchDocument makes a single request for a URL and parses the response. It
+// also returns the response status, 0 when there was no response, and
+// whether the request is worth retrying.
+func fetchDocument(ctx context.Context, url string) (*goquery.Document, int, bool, error) {
+	req, err := newRequest(ctx, http.MethodGet, url)
 	if err != nil {
-		log.Fatal(err)
+		return nil, 0, false, err
+	}
+
+	stale, validators, revalidate := cache.stale(url)
+	revalidate = revalidate && cacheReadable(ctx)
+	if revalidate {
+		validators.setConditional(req)
+	}
+
+	release, err := hostSlots.acquire(ctx, req.URL.Host)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	defer release()
+
+	if err := limiter.wait(ctx); err != nil {
+		return nil, 0, false, err
+	}
+
+	// the timeout only counts from the request on, not the waits above.
+	// ctx stays the context of the query, so that a request timing out
+	// is retried while a cancelled query is not.
+	reqCtx, cancel := requestContext(ctx)
+	defer cancel()
+
+	start := time.Now()
+	res, err := client.Do(req.WithContext(reqCtx))
+	if err != nil {
+		if ctx.Err() != nil {
+			recorder.fetchError("timeout")
+			return nil, 0, false, err
+		}
+		recorder.fetchError("network")
+		return nil, 0, true, err
 	}
 	defer res.Body.Close()
+	recorder.fetch(time.Since(start))
+	if len(opts.showHeaders) > 0 {
+		metaFrom(ctx).Headers = responseHeaders(res.Header)
+	}
+
+	// the cached page is still current. A server that ignores the
+	// validators answers 200 instead, and its page replaces the entry below.
+	if revalidate && res.StatusCode == http.StatusNotModified {
+		if err := cache.refresh(url); err != nil {
+			log.Printf("cache: %v", err)
+		}
+		recorder.cacheHit()
+		metaFrom(ctx).Cached = true
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(stale))
+		return doc, http.StatusOK, false, err
+	}
 
 	// check for status code error
 	if res.StatusCode != 200 {
-		log.Printf("status code error: %d", res.StatusCode)
+		recorder.fetchError("status")
+	}
+
+	body, err := readBody(res.Body)
+	if errors.Is(err, errBodyTooLarge) {
+		recorder.fetchError("size")
+		return nil, res.StatusCode, false, err
+	}
+	if err != nil {
+		recorder.fetchError("network")
+		return nil, res.StatusCode, ctx.Err() == nil, err
 	}
 
 	// load the HTML document
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		recorder.fetchError("parse")
+		return nil, res.StatusCode, false, err
+	}
+
+	if res.StatusCode == http.StatusOK && !opts.noCache {
+		if err := cache.put(ctx, url, body, validatorsFrom(res.Header)); err != nil {
+			log.Printf("cache: %v", err)
+		}
+	}
+	return doc, res.StatusCode, retryable(res.StatusCode), nil
+}
+
+// loadHTMLFile parses a saved HTML page from disk in place of fetching it
+func loadHTMLFile(path string) (*goquery.Document, error) {
+	f, err := os.Open(path)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("-html-file: %w", err)
 	}
+	defer f.Close()
 
-	return doc
+	return goquery.NewDocumentFromReader(f)
 }
 
-// queryIP query for information about the IP address and print json results
-func queryIP(doc *goquery.Document) {
-	var rows []IPInfo
+// queryIP query for information about the IP address and return the results.
+// The announcement, DNS and whois tabs are parsed separately, each scoped to
+// its own container.
+func queryIP(doc *goquery.Document, q query) interface{} {
+	res := IPResult{IP: q.Value}
+
+	// the origin and AS path are shown either as table columns or as labels
+	// next to the table, and are often missing altogether
+	ipinfo := doc.Find("#ipinfo")
+	origin := labelValue(ipinfo, "Origin AS")
+	path := labelValue(ipinfo, "AS Path")
+	registry := pageRegistry(doc)
 
-	doc.Find("tbody tr").Each(func(i int, row *goquery.Selection) {
+	tableRows(doc, "#ipinfo tbody tr").Each(func(i int, row *goquery.Selection) {
 		asn := strings.TrimSpace(row.Find("td").Eq(0).Text())
 		net := strings.TrimSpace(row.Find("td").Eq(1).Text())
 		des := strings.TrimSpace(row.Find("td").Eq(2).Text())
 
-		res := IPInfo{ASN: asn, Network: net, Description: des}
-		rows = append(rows, res)
+		info := IPInfo{ASN: asn, Network: net, Description: des,
+			Country: rowCountry(row), RPKI: rowRPKI(row),
+			Origin: origin, ASPath: path, Registry: rowRegistry(row, registry),
+			Malformed: shortRow(row, 3)}
+		// some layouts show the AS name in its own column next to the
+		// prefix description, shifting the columns after it
+		if col := headerIndex(row, "as name", "asn name"); col >= 0 {
+			info.ASNName = strings.TrimSpace(row.Find("td").Eq(col).Text())
+			info.Network = cellText(row, 2, "prefix", "network")
+			info.Description = cellText(row, 3, "description")
+			info.Malformed = shortRow(row, 4)
+		}
+		if col := headerIndex(row, "origin"); col >= 0 {
+			info.Origin = strings.TrimSpace(row.Find("td").Eq(col).Text())
+		}
+		if col := headerIndex(row, "as path", "as-path"); col >= 0 {
+			info.ASPath = strings.Join(strings.Fields(row.Find("td").Eq(col).Text()), " ")
+		}
+		info.URL = rowURL(row, 1, []string{"prefix", "network"}, netSegments(info.Network)...)
+		res.Announcement = append(res.Announcement, info)
 	})
+	markMultiOrigin(res.Announcement)
+
+	// IPv6 pages head the records column AAAA and may write the addresses in
+	// another form than the query, so columns go by header and addresses are
+	// normalized
+	doc.Find("#dns tbody tr").Each(func(i int, row *goquery.Selection) {
+		ip := cellText(row, 0, "ip")
+		if addr := net.ParseIP(ip); addr != nil {
+			ip = addr.String()
+		}
+		ptr := cellText(row, 1, "ptr")
+		rec := cellText(row, 2, "record", "aaaa")
+
+		info := DNSInfo{IP: ip, PTR: ptr, ARecords: rec, Malformed: shortRow(row, 3),
+			URL: rowURL(row, 1, []string{"ptr"}, "dns", ptr)}
+		res.DNS = append(res.DNS, info)
+	})
+
+	res.Whois = strings.TrimSpace(doc.Find("#whois pre").Text())
+	res.WhoisSources = whoisSources(doc)
 
-	printJSON(rows)
+	routed := len(res.Announcement) > 0
+	if routed || notRoutedPattern.MatchString(ipinfo.Text()) {
+		res.Routed = &routed
+	}
+
+	return res
 }
 
-// queryNET query for Network Address block and print json results
-func queryNET(doc *goquery.Document) {
+// queryNET query for Network Address block and return the results
+func queryNET(doc *goquery.Document, _ query) interface{} {
 	var rows []NETInfo
+	registry := pageRegistry(doc)
 
 	doc.Find("#netinfo tbody tr").Each(func(i int, row *goquery.Selection) {
 		asn := strings.TrimSpace(row.Find("td").Eq(0).Text())
 		net := strings.TrimSpace(row.Find("td").Eq(1).Text())
 		des := strings.TrimSpace(row.Find("td").Eq(2).Text())
 
-		res := NETInfo{ASN: asn, Network: net, Description: des}
+		res := NETInfo{ASN: asn, Network: net, Description: des,
+			Country: rowCountry(row), RPKI: rowRPKI(row),
+			Registry: rowRegistry(row, registry), Malformed: shortRow(row, 3),
+			URL: rowURL(row, 1, nil, netSegments(net)...)}
 		rows = append(rows, res)
 
 	})
+	markMultiOriginNET(rows)
 
-	printJSON(rows)
+	return rows
 }
 
-// queryORG query for network information using organization name and print
-// results in json
-func queryORG(doc *goquery.Document) {
+// queryORG query for network information using organization name and return
+// the results
+func queryORG(doc *goquery.Document, _ query) interface{} {
 	var rows []ORGInfo
 
-	doc.Find("tbody tr").Each(func(i int, row *goquery.Selection) {
+	tableRows(doc, "tbody tr").Each(func(i int, row *goquery.Selection) {
 		result := strings.TrimSpace(row.Find("td").Eq(0).Text())
 		kind := strings.TrimSpace(row.Find("td").Eq(1).Text())
 		des := strings.TrimSpace(row.Find("td").Eq(2).Text())
 
-		res := ORGInfo{Result: result, Type: kind, Description: des}
+		res := ORGInfo{Result: result, Type: normalizeOrgType(kind),
+			RawType: kind, Description: des, Country: rowCountry(row),
+			Malformed: shortRow(row, 3)}
+		if res.Type == orgTypeDNS {
+			res.Hostname = strings.TrimSuffix(strings.ToLower(result), ".")
+			res.Addresses = dnsAddresses(des)
+		}
+		res.URL = rowURL(row, 0, nil, orgSegments(res)...)
 		rows = append(rows, res)
 
 	})
 
-	printJSON(rows)
+	return rows
 }
 
-// queryASN query for ASN number and print results in json
-func queryASN(doc *goquery.Document) {
+// dnsAddresses returns the IPs and prefixes listed in the description of a
+// DNS search result, normalized and in the order shown
+func dnsAddresses(des string) []string {
+	var addrs []string
+	for _, field := range strings.FieldsFunc(des, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}) {
+		if ip := net.ParseIP(field); ip != nil {
+			addrs = append(addrs, ip.String())
+		} else if _, ipnet, err := net.ParseCIDR(field); err == nil {
+			addrs = append(addrs, ipnet.String())
+		}
+	}
+	return addrs
+}
+
+// queryASN query for ASN number and return the results, the rows of every
+// prefix table of the page tagged with their table
+func queryASN(doc *goquery.Document, q query) interface{} {
 	var rows []ASNInfo
+	_, country := asnHeader(doc, q.Value)
 
-	doc.Find("#table_prefixes4 tbody tr").Each(func(i int,
-		row *goquery.Selection) {
+	eachPrefixRow(doc, func(row *goquery.Selection, table, category string) {
 		pref := strings.TrimSpace(row.Find("td").Eq(0).Text())
 		des := strings.TrimSpace(row.Find("td").Eq(1).Text())
 
-		res := ASNInfo{Prefix: pref, Description: des}
+		res := ASNInfo{Prefix: pref, Description: des, Country: rowCountry(row),
+			RPKI: rowRPKI(row), ASCountry: country, Table: table, Category: category,
+			Malformed: shortRow(row, 2), URL: rowURL(row, 0, nil, netSegments(pref)...)}
 		rows = append(rows, res)
 	})
 
-	printJSON(rows)
+	return rows
 }
 
-// printJSON Print the given data as JSON
-func printJSON(data interface{}) {
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		log.Fatal(err)
-		return
+// selectTable returns the table picked with -select-table, either the nth
+// table of the page counting from 1 or the element with the given id, or nil
+// when the flag is not set.
+func selectTable(doc *goquery.Document) (*goquery.Selection, error) {
+	if opts.selectTable == "" {
+		return nil, nil
+	}
+
+	if n, err := strconv.Atoi(opts.selectTable); err == nil {
+		tables := doc.Find("table")
+		if n < 1 || n > tables.Length() {
+			return nil, fmt.Errorf("-select-table %d out of range, the page has %d tables",
+				n, tables.Length())
+		}
+		return tables.Eq(n - 1), nil
+	}
+
+	table := doc.Find(fmt.Sprintf("[id=%q]", opts.selectTable))
+	if table.Length() == 0 {
+		return nil, fmt.Errorf("-select-table: no element with id %q on the page",
+			opts.selectTable)
+	}
+	return table.First(), nil
+}
+
+// tableRows returns the rows matched by selector, or the rows of the table
+// picked with -select-table instead when it is set. The table is validated by
+// runQuery before parsing.
+func tableRows(doc *goquery.Document, selector string) *goquery.Selection {
+	if table, _ := selectTable(doc); table != nil {
+		return table.Find("tbody tr")
+	}
+	return doc.Find(selector)
+}
+
+// headerIndex returns the index of the first column of the row's table whose
+// header contains one of the names, ignoring case, or -1 when there is none
+func headerIndex(row *goquery.Selection, names ...string) int {
+	index := -1
+	row.Closest("table").Find("thead th").EachWithBreak(func(i int,
+		th *goquery.Selection) bool {
+		header := strings.ToLower(th.Text())
+		for _, name := range names {
+			if strings.Contains(header, name) {
+				index = i
+				return false
+			}
+		}
+		return true
+	})
+	return index
+}
+
+// labelValue returns the text following "label:" on the same line within sel,
+// or an empty string when the label is not shown
+func labelValue(sel *goquery.Selection, label string) string {
+	for _, line := range strings.Split(sel.Text(), "\n") {
+		_, value, ok := strings.Cut(line, label+":")
+		if ok {
+			return strings.Join(strings.Fields(value), " ")
+		}
 	}
+	return ""
+}
+
+// shortRow reports whether a table row has fewer cells than the parser reads,
+// which would leave fields of the parsed row empty
+func shortRow(row *goquery.Selection, cells int) bool {
+	return row.Find("td").Length() < cells
+}
+
+// whoisSources splits the whois section of a page into its blocks, keyed by
+// the source named in each block, such as an RIR and a national registry
+// answering for the same prefix. A block without a source line is keyed by
+// the heading before it, or by its position. Blocks of the same source are
+// joined.
+func whoisSources(doc *goquery.Document) map[string]string {
+	sources := map[string]string{}
+	doc.Find("#whois pre").Each(func(i int, pre *goquery.Selection) {
+		text := strings.TrimSpace(pre.Text())
+		if text == "" {
+			return
+		}
 
-	fmt.Println(string(jsonData))
+		source := strings.ToUpper(labelValue(pre, "source"))
+		if source == "" {
+			source = strings.TrimSpace(pre.PrevAll().Filter("h1, h2, h3, h4, b, strong").First().Text())
+		}
+		if source == "" {
+			source = fmt.Sprintf("whois%d", i+1)
+		}
+
+		if prev, ok := sources[source]; ok {
+			text = prev + "\n\n" + text
+		}
+		sources[source] = text
+	})
+	if len(sources) == 0 {
+		return nil
+	}
+	return sources
 }
 
-// showHelpMessage print the help message
-func showHelpMessage() {
-	fmt.Printf("Usage: %s [OPTIONS]\n\n", os.Args[0])
-	fmt.Printf("Options:\n")
-	flag.PrintDefaults()
-	fmt.Printf("\nExamples:")
-	fmt.Printf("\n  %s -asn AS63293", os.Args[0])
-	fmt.Printf("\n  %s -ip 1.1.1.1", os.Args[0])
-	fmt.Printf("\n  %s -net 41.223.111.0/22", os.Args[0])
-	fmt.Printf("\n  %s -org facebook\n", os.Args[0])
+// flagSelector matches the flag images of a page, in an element of the flag
+// class or by the path of the image
+const flagSelector = ".flag img, img[src*='/flags/']"
+
+// flagPattern matches the country code in the file name of a flag image
+var flagPattern = regexp.MustCompile(`(?i)/flags/([a-z]{2})\.\w+$`)
+
+// flagCountry returns the country code of a flag image. The code is usually
+// the alt text, but some cells only carry it in the title, or name the
+// country in full and leave the code to the image's file name.
+func flagCountry(img *goquery.Selection) string {
+	for _, attr := range []string{"alt", "title"} {
+		code, _ := img.Attr(attr)
+		if code = strings.TrimSpace(code); len(code) == 2 {
+			return strings.ToUpper(code)
+		}
+	}
+	src, _ := img.Attr("src")
+	if m := flagPattern.FindStringSubmatch(src); m != nil {
+		return strings.ToUpper(m[1])
+	}
+	return ""
+}
+
+// rowCountry returns the country code of the flag shown in a table row, such
+// as in its description cell, or an empty string when the row has no flag
+func rowCountry(row *goquery.Selection) string {
+	return flagCountry(row.Find(flagSelector).First())
+}
+
+// rowRPKI returns the RPKI validity of the prefix in a table row, read from
+// the ROA indicator icon or label: "valid", "invalid", or "unknown" when the
+// row has no indicator
+func rowRPKI(row *goquery.Selection) string {
+	status := "unknown"
+	row.Find("img, [class*=rpki], [class*=roa]").EachWithBreak(func(i int,
+		sel *goquery.Selection) bool {
+		alt, _ := sel.Attr("alt")
+		title, _ := sel.Attr("title")
+		src, _ := sel.Attr("src")
+		class, _ := sel.Attr("class")
+		hint := strings.ToLower(strings.Join([]string{alt, title, src, class,
+			sel.Text()}, " "))
+
+		if !strings.Contains(hint, "rpki") && !strings.Contains(hint, "roa") {
+			return true
+		}
+		switch {
+		case strings.Contains(hint, "invalid"):
+			status = "invalid"
+		case strings.Contains(hint, "valid"):
+			status = "valid"
+		default:
+			return true
+		}
+		return false
+	})
+	return status
+}
+
+// printJSON Print the given data as JSON
+func printJSON(data interface{}) error {
+	return jsonWriter{w: os.Stdout}.Write(query{}, data)
 }
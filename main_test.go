@@ -0,0 +1,595 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// loadFixture parses a page of testdata
+func loadFixture(t *testing.T, name string) *goquery.Document {
+	t.Helper()
+	doc, err := loadHTMLFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// checkResult fails the test when got differs from want, showing both as JSON
+func checkResult(t *testing.T, got, want interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		g, _ := json.MarshalIndent(got, "", "  ")
+		w, _ := json.MarshalIndent(want, "", "  ")
+		t.Errorf("got\n%s\nwant\n%s", g, w)
+	}
+}
+
+// runArgs performs the queries with the options parsed from args, as a
+// command would, and returns the exit code and the output, written to a file
+func runArgs(t *testing.T, queries []query, batch bool, args ...string) (int, string) {
+	t.Helper()
+	saved := opts
+	// the client, limiters and cache of the run are set up by run
+	savedClient, savedLimiter, savedRetries, savedSlots, savedCache := client, limiter, retries, hostSlots, cache
+	t.Cleanup(func() {
+		opts = saved
+		client, limiter, retries, hostSlots, cache = savedClient, savedLimiter, savedRetries, savedSlots, savedCache
+	})
+
+	opts = options{}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	opts.register(fs)
+	out := filepath.Join(t.TempDir(), "out.json")
+	if err := fs.Parse(append(args, "-o", out)); err != nil {
+		t.Fatal(err)
+	}
+
+	code := run(queries, batch)
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code, string(data)
+}
+
+// boolPtr returns a pointer to b, for the optional fields of a result
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// the whois blocks of testdata/ip-two-whois.html
+const (
+	twoWhoisAPNIC = "inetnum:        202.12.27.0 - 202.12.27.255\n" +
+		"netname:        M-ROOT\n" +
+		"country:        JP\n" +
+		"source:         APNIC"
+	twoWhoisJPNIC = "Network Number: 202.12.27.0/24\n" +
+		"Network Name:   M-ROOT\n" +
+		"Organization:   WIDE Project"
+)
+
+// the whois of testdata/ip-v6.html
+const v6Whois = "NetRange:       2606:4700:: - 2606:4700:FFFF:FFFF:FFFF:FFFF:FFFF:FFFF\n" +
+	"OrgName:        Cloudflare, Inc."
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := []struct {
+		in, want string
+		wantErr  bool
+	}{
+		{"1.1.1.1", "1.1.1.1", false},
+		{"2606:4700:4700:0:0:0:0:1111", "2606:4700:4700::1111", false},
+		{"2606:4700:4700:0000:0000:0000:0000:1111", "2606:4700:4700::1111", false},
+		{"2001:DB8::1", "2001:db8::1", false},
+		{"::ffff:192.0.2.1", "192.0.2.1", false},
+		{"1.1.1.999", "", true},
+	}
+	for _, tt := range tests {
+		q, err := normalizeQuery(query{Type: "ip", Value: tt.in})
+		if tt.wantErr != (err != nil) || !tt.wantErr && q.Value != tt.want {
+			t.Errorf("%s: got %q and %v, want %q", tt.in, q.Value, err, tt.want)
+		}
+	}
+}
+
+func TestQueryIP(t *testing.T) {
+	tests := []struct {
+		file string
+		ip   string
+		want IPResult
+	}{
+		{
+			// no origin nor AS path shown, both left out
+			file: "ip-sections.html",
+			ip:   "8.8.8.8",
+			want: IPResult{
+				IP:     "8.8.8.8",
+				Routed: boolPtr(true),
+				Announcement: []IPInfo{
+					{ASN: "AS15169", Network: "8.8.8.0/24", Description: "Google LLC",
+						Country: "US", RPKI: "unknown", Registry: "ARIN",
+						URL: "https://bgp.he.net/net/8.8.8.0/24"},
+					{ASN: "AS15169", Network: "8.0.0.0/12", Description: "Google LLC",
+						Country: "US", RPKI: "unknown", Registry: "ARIN",
+						URL: "https://bgp.he.net/net/8.0.0.0/12"},
+				},
+				DNS: []DNSInfo{
+					{IP: "8.8.8.8", PTR: "dns.google", ARecords: "dns.google",
+						URL: "https://bgp.he.net/dns/dns.google"},
+				},
+				Whois: "NetRange:       8.8.8.0 - 8.8.8.255\nCIDR:           8.8.8.0/24\nOrgName:        Google LLC",
+				WhoisSources: map[string]string{
+					"whois1": "NetRange:       8.8.8.0 - 8.8.8.255\nCIDR:           8.8.8.0/24\nOrgName:        Google LLC",
+				},
+			},
+		},
+		{
+			file: "ip-origin.html",
+			ip:   "1.1.1.1",
+			want: IPResult{
+				IP:     "1.1.1.1",
+				Routed: boolPtr(true),
+				Announcement: []IPInfo{
+					{ASN: "AS13335", Network: "1.1.1.0/24", Country: "AU", RPKI: "unknown",
+						Description: "APNIC and Cloudflare DNS Resolver project", Origin: "AS13335",
+						ASPath: "6939 13335", URL: "https://bgp.he.net/net/1.1.1.0/24"},
+				},
+			},
+		},
+		{
+			file: "ip-origin-labels.html",
+			ip:   "1.1.1.1",
+			want: IPResult{
+				IP:     "1.1.1.1",
+				Routed: boolPtr(true),
+				Announcement: []IPInfo{
+					{ASN: "AS13335", Network: "1.1.1.0/24", Country: "AU", RPKI: "unknown",
+						Description: "APNIC and Cloudflare DNS Resolver project", Origin: "AS13335",
+						ASPath: "174 13335", URL: "https://bgp.he.net/net/1.1.1.0/24"},
+				},
+			},
+		},
+		{
+			file: "ip-not-routed.html",
+			ip:   "192.0.2.1",
+			want: IPResult{
+				IP:     "192.0.2.1",
+				Routed: boolPtr(false),
+				Whois:  "inetnum:        192.0.2.0 - 192.0.2.255\nnetname:        TEST-NET-1\nsource:         RIPE",
+				WhoisSources: map[string]string{
+					"RIPE": "inetnum:        192.0.2.0 - 192.0.2.255\nnetname:        TEST-NET-1\nsource:         RIPE",
+				},
+			},
+		},
+		{
+			// the AS name has a column of its own, apart from the description
+			file: "ip-asn-name.html",
+			ip:   "1.1.1.1",
+			want: IPResult{
+				IP:     "1.1.1.1",
+				Routed: boolPtr(true),
+				Announcement: []IPInfo{
+					{ASN: "AS13335", ASNName: "Cloudflare, Inc.", Network: "1.1.1.0/24",
+						Description: "APNIC and Cloudflare DNS Resolver project", Country: "AU",
+						RPKI: "unknown", URL: "https://bgp.he.net/net/1.1.1.0/24"},
+				},
+			},
+		},
+		{
+			file: "ip-two-whois.html",
+			ip:   "202.12.27.33",
+			want: IPResult{
+				IP:     "202.12.27.33",
+				Routed: boolPtr(true),
+				Announcement: []IPInfo{
+					{ASN: "AS7500", Network: "202.12.27.0/24", Description: "M-ROOT DNS Server",
+						Country: "JP", RPKI: "unknown", Registry: "APNIC",
+						URL: "https://bgp.he.net/net/202.12.27.0/24"},
+				},
+				Whois:        twoWhoisAPNIC + "\n" + twoWhoisJPNIC,
+				WhoisSources: map[string]string{"APNIC": twoWhoisAPNIC, "JPNIC": twoWhoisJPNIC},
+			},
+		},
+		{
+			// the DNS row of the address, written in full, under an AAAA
+			// Records header
+			file: "ip-v6.html",
+			ip:   "2606:4700:4700::1111",
+			want: IPResult{
+				IP:     "2606:4700:4700::1111",
+				Routed: boolPtr(true),
+				Announcement: []IPInfo{
+					{ASN: "AS13335", Network: "2606:4700:4700::/48", Description: "Cloudflare, Inc.",
+						Country: "US", RPKI: "unknown", Registry: "ARIN",
+						URL: "https://bgp.he.net/net/2606:4700:4700::/48"},
+				},
+				DNS: []DNSInfo{
+					{IP: "2606:4700:4700::1111", PTR: "one.one.one.one", ARecords: "one.one.one.one",
+						URL: "https://bgp.he.net/dns/one.one.one.one"},
+				},
+				Whois:        v6Whois,
+				WhoisSources: map[string]string{"whois1": v6Whois},
+			},
+		},
+		{
+			file: "ip-multi-origin.html",
+			ip:   "192.0.2.10",
+			want: IPResult{
+				IP:     "192.0.2.10",
+				Routed: boolPtr(true),
+				// the prefix written with host bits set is the same /24
+				Announcement: []IPInfo{
+					{ASN: "AS64500", Network: "192.0.2.0/24", Description: "Example Networks",
+						Country: "US", RPKI: "unknown", URL: "https://bgp.he.net/net/192.0.2.0/24",
+						MultiOrigin: true, OriginASNs: []string{"AS64500", "AS64511"}},
+					{ASN: "AS64511", Network: "192.0.2.1/24", Description: "Unexpected Origin B.V.",
+						Country: "NL", RPKI: "unknown", URL: "https://bgp.he.net/net/192.0.2.0/24",
+						MultiOrigin: true, OriginASNs: []string{"AS64500", "AS64511"}},
+					{ASN: "AS64500", Network: "192.0.2.0/25", Description: "Example Networks",
+						Country: "US", RPKI: "unknown", URL: "https://bgp.he.net/net/192.0.2.0/25"},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			doc := loadFixture(t, tt.file)
+			checkResult(t, queryIP(doc, query{Type: "ip", Value: tt.ip}), tt.want)
+		})
+	}
+}
+
+func TestQueryASN(t *testing.T) {
+	tests := []struct {
+		file string
+		asn  string
+		want []ASNInfo
+	}{
+		{
+			file: "asn-rpki.html",
+			asn:  "AS64502",
+			want: []ASNInfo{
+				{Prefix: "192.0.2.0/24", Description: "Example Signed", RPKI: "valid",
+					Table: "table_prefixes4", Category: "originated",
+					URL: "https://bgp.he.net/net/192.0.2.0/24"},
+				{Prefix: "198.51.100.0/24", Description: "Example Signed", RPKI: "invalid",
+					Table: "table_prefixes4", Category: "originated",
+					URL: "https://bgp.he.net/net/198.51.100.0/24"},
+				{Prefix: "203.0.113.0/24", Description: "Example Signed", RPKI: "unknown",
+					Table: "table_prefixes4", Category: "originated",
+					URL: "https://bgp.he.net/net/203.0.113.0/24"},
+			},
+		},
+		{
+			file: "asn-prefix-tables.html",
+			asn:  "AS64501",
+			want: []ASNInfo{
+				{Prefix: "192.0.2.0/24", Description: "Example Transit", RPKI: "unknown",
+					Table: "table_prefixes4", Category: "originated",
+					URL: "https://bgp.he.net/net/192.0.2.0/24"},
+				{Prefix: "198.51.100.0/24", Description: "Example Transit", RPKI: "unknown",
+					Table: "table_prefixes4", Category: "originated",
+					URL: "https://bgp.he.net/net/198.51.100.0/24"},
+				{Prefix: "2001:db8::/32", Description: "Example Transit", RPKI: "unknown",
+					Table: "table_prefixes6", Category: "originated",
+					URL: "https://bgp.he.net/net/2001:db8::/32"},
+				{Prefix: "203.0.113.0/24", Description: "Example Customer", RPKI: "unknown",
+					Table: "table_prefixes4_transit", Category: "transit",
+					URL: "https://bgp.he.net/net/203.0.113.0/24"},
+				{Prefix: "203.0.113.128/25", Description: "Example Customer", RPKI: "unknown",
+					Table: "table_prefixes4_transit", Category: "transit",
+					URL: "https://bgp.he.net/net/203.0.113.128/25"},
+				{Prefix: "2001:db8:1000::/36", Description: "Example Customer", RPKI: "unknown",
+					Table: "table_prefixes6_transit", Category: "transit",
+					URL: "https://bgp.he.net/net/2001:db8:1000::/36"},
+			},
+		},
+		{
+			file: "asn-header.html",
+			asn:  "AS13335",
+			want: []ASNInfo{
+				{Prefix: "1.1.1.0/24", Description: "APNIC and Cloudflare DNS Resolver project",
+					Country: "AU", RPKI: "unknown", ASCountry: "US",
+					Table: "table_prefixes4", Category: "originated",
+					URL: "https://bgp.he.net/net/1.1.1.0/24"},
+				{Prefix: "104.16.0.0/13", Description: "Cloudflare, Inc.",
+					Country: "US", RPKI: "unknown", ASCountry: "US",
+					Table: "table_prefixes4", Category: "originated",
+					URL: "https://bgp.he.net/net/104.16.0.0/13"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			doc := loadFixture(t, tt.file)
+			checkResult(t, queryASN(doc, query{Type: "asn", Value: tt.asn}), tt.want)
+		})
+	}
+}
+
+func TestQueryNET(t *testing.T) {
+	tests := []struct {
+		file string
+		net  string
+		want []NETInfo
+	}{
+		{
+			// countries from the title of a flag image, or from the file
+			// name of one whose alt text names the country in full
+			file: "net-flag-title.html",
+			net:  "1.0.0.0/24",
+			want: []NETInfo{
+				{ASN: "AS13335", Network: "1.0.0.0/24",
+					Description: "APNIC and Cloudflare DNS Resolver project", Country: "AU",
+					RPKI: "unknown", URL: "https://bgp.he.net/net/1.0.0.0/24",
+					MultiOrigin: true, OriginASNs: []string{"AS13335", "AS9808"}},
+				{ASN: "AS9808", Network: "1.0.0.0/24", Description: "China Mobile", Country: "CN",
+					RPKI: "unknown", URL: "https://bgp.he.net/net/1.0.0.0/24",
+					MultiOrigin: true, OriginASNs: []string{"AS13335", "AS9808"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			doc := loadFixture(t, tt.file)
+			checkResult(t, queryNET(doc, query{Type: "net", Value: tt.net}), tt.want)
+		})
+	}
+}
+
+func TestASNHeader(t *testing.T) {
+	tests := []struct {
+		file, asn     string
+		name, country string
+	}{
+		{"asn-header.html", "AS13335", "Cloudflare, Inc.", "US"},
+		{"asn-prefix-tables.html", "AS64501", "Example Transit", ""},
+	}
+	for _, tt := range tests {
+		name, country := asnHeader(loadFixture(t, tt.file), tt.asn)
+		if name != tt.name || country != tt.country {
+			t.Errorf("%s: got %q and %q, want %q and %q", tt.file, name, country, tt.name, tt.country)
+		}
+	}
+}
+
+// the description of the hostname row of testdata/org-dns.html
+const hostnameV6 = "2606:4700:4700:0000:0000:0000:0000:1111 2606:4700:4700::/48"
+
+func TestQueryORG(t *testing.T) {
+	tests := []struct {
+		file string
+		want []ORGInfo
+	}{
+		{
+			file: "org-types.html",
+			want: []ORGInfo{
+				{Result: "AS64500", Type: "asn", RawType: "ASN", Description: "Example Networks",
+					URL: "https://bgp.he.net/AS64500"},
+				{Result: "AS64501", Type: "asn", RawType: "AS", Description: "Example Transit",
+					URL: "https://bgp.he.net/AS64501"},
+				{Result: "192.0.2.0/24", Type: "net", RawType: "Route", Description: "Example Networks",
+					URL: "https://bgp.he.net/net/192.0.2.0/24"},
+				{Result: "198.51.100.0/24", Type: "net", RawType: "Network", Description: "Example Networks",
+					URL: "https://bgp.he.net/net/198.51.100.0/24"},
+				{Result: "203.0.113.0/24", Type: "net", RawType: "Prefix", Description: "Example Networks",
+					URL: "https://bgp.he.net/net/203.0.113.0/24"},
+				{Result: "192.0.2.128/25", Type: "net", RawType: "IPv4", Description: "Example Networks",
+					URL: "https://bgp.he.net/net/192.0.2.128/25"},
+				{Result: "2001:db8::/32", Type: "net", RawType: "IPv6", Description: "Example Networks",
+					URL: "https://bgp.he.net/net/2001:db8::/32"},
+				{Result: "Example Networks", Type: "org", RawType: "Org", Description: "Example Networks Inc."},
+				{Result: "Example Transit", Type: "org", RawType: "Organization", Description: "Example Transit Ltd."},
+				{Result: "Example Carrier", Type: "org", RawType: "organisation", Description: "Example Carrier GmbH"},
+				{Result: "EX-IX", Type: "ix", RawType: "IX", Description: "Example Exchange"},
+				{Result: "EX-IX Fra", Type: "ix", RawType: "Exchange", Description: "Example Exchange Frankfurt"},
+				{Result: "EX-IX Ams", Type: "ix", RawType: "Internet Exchange", Description: "Example Exchange Amsterdam"},
+				{Result: "example.net", Type: "dns", RawType: "DNS", Description: "192.0.2.1",
+					Hostname: "example.net", Addresses: []string{"192.0.2.1"},
+					URL: "https://bgp.he.net/dns/example.net"},
+				{Result: "www.example.net", Type: "dns", RawType: "Hostname", Description: "192.0.2.2",
+					Hostname: "www.example.net", Addresses: []string{"192.0.2.2"},
+					URL: "https://bgp.he.net/dns/www.example.net"},
+				{Result: "example.org", Type: "dns", RawType: "Domain", Description: "192.0.2.3",
+					Hostname: "example.org", Addresses: []string{"192.0.2.3"},
+					URL: "https://bgp.he.net/dns/example.org"},
+				{Result: "Example Facility", Type: "unknown", RawType: "Facility", Description: "Example Data Center"},
+			},
+		},
+		{
+			// the addresses of a hostname, separated by commas or spaces,
+			// normalized, and its name without the trailing dot
+			file: "org-dns.html",
+			want: []ORGInfo{
+				{Result: "one.one.one.one", Type: "dns", RawType: "DNS", Description: "1.1.1.1, 1.0.0.1",
+					Hostname: "one.one.one.one", Addresses: []string{"1.1.1.1", "1.0.0.1"},
+					URL: "https://bgp.he.net/dns/one.one.one.one"},
+				{Result: "One.One.One.One.", Type: "dns", RawType: "Hostname", Description: hostnameV6,
+					Hostname: "one.one.one.one", Addresses: []string{"2606:4700:4700::1111", "2606:4700:4700::/48"},
+					URL: "https://bgp.he.net/dns/One.One.One.One."},
+				{Result: "AS13335", Type: "asn", RawType: "ASN", Description: "Cloudflare, Inc.", Country: "US",
+					URL: "https://bgp.he.net/AS13335"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			doc := loadFixture(t, tt.file)
+			checkResult(t, queryORG(doc, query{Type: "org", Value: "example"}), tt.want)
+		})
+	}
+}
+
+func TestAllowEmpty(t *testing.T) {
+	// the page has no prefix tables, so the ASN result is empty
+	queries := []query{{Type: "asn", Value: "AS64496"}}
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{nil, exitEmpty},
+		{[]string{"-allow-empty"}, exitOK},
+	}
+	for _, tt := range tests {
+		code, out := runArgs(t, queries, false,
+			append(tt.args, "-html-file", "testdata/ip-not-routed.html")...)
+		if code != tt.want {
+			t.Errorf("%v: exit code %d, want %d", tt.args, code, tt.want)
+		}
+		// the empty result is printed either way
+		if out == "" {
+			t.Errorf("%v: empty result not printed", tt.args)
+		}
+	}
+}
+
+func TestSearchURL(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Deutsche Telekom", "https://bgp.he.net/search?commit=Search&search%5Bsearch%5D=Deutsche+Telekom"},
+		{"Zürich Telekom", "https://bgp.he.net/search?commit=Search&search%5Bsearch%5D=Z%C3%BCrich+Telekom"},
+		// punycode is searched as the Unicode name shown on the site
+		{"xn--zrich-kva.example", "https://bgp.he.net/search?commit=Search&search%5Bsearch%5D=z%C3%BCrich.example"},
+	}
+	for _, tt := range tests {
+		if got := queryURL(BaseURL, query{Type: "org", Value: tt.value}); got != tt.want {
+			t.Errorf("org %q: got %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestQueryURLEscaping(t *testing.T) {
+	tests := []struct {
+		q    query
+		want string
+	}{
+		{query{Type: "asn", Value: "AS13335"}, "https://bgp.he.net/AS13335"},
+		{query{Type: "asn", Value: "AS1 ?#"}, "https://bgp.he.net/AS1%20%3F%23"},
+		{query{Type: "ip", Value: "1.1.1.1"}, "https://bgp.he.net/ip/1.1.1.1"},
+		// a slash cannot climb out of the ip path
+		{query{Type: "ip", Value: "1.1.1.1/../AS1"}, "https://bgp.he.net/ip/1.1.1.1%2F..%2FAS1"},
+		{query{Type: "ip", Value: "2001:db8::1"}, "https://bgp.he.net/ip/2001:db8::1"},
+		// only the slash of a network block is kept
+		{query{Type: "net", Value: "1.1.1.0/24"}, "https://bgp.he.net/net/1.1.1.0/24"},
+		{query{Type: "net", Value: "10.0.0.0/8?x=1&y=%"}, "https://bgp.he.net/net/10.0.0.0/8%3Fx=1&y=%25"},
+		{query{Type: "org", Value: "a&b #c %d/e?"},
+			"https://bgp.he.net/search?commit=Search&search%5Bsearch%5D=a%26b+%23c+%25d%2Fe%3F"},
+		{query{Type: "find-asn", Value: "x&commit=Evil"},
+			"https://bgp.he.net/search?commit=Search&search%5Bsearch%5D=x%26commit%3DEvil"},
+	}
+	for _, tt := range tests {
+		if got := queryURL(BaseURL, tt.q); got != tt.want {
+			t.Errorf("%s %q: got %s, want %s", tt.q.Type, tt.q.Value, got, tt.want)
+		}
+	}
+}
+
+func TestSetPath(t *testing.T) {
+	tests := []struct {
+		base     string
+		segments []string
+		want     string
+	}{
+		{"https://bgp.he.net", []string{"dns", "one.one.one.one"}, "https://bgp.he.net/dns/one.one.one.one"},
+		// a mirror serving the site under a path of its own keeps it
+		{"https://mirror.example/he/", []string{"net", "a b%"}, "https://mirror.example/he/net/a%20b%25"},
+		{"https://mirror.example/he", []string{"AS1#x", "?"}, "https://mirror.example/he/AS1%23x/%3F"},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		setPath(u, tt.segments...)
+		if got := u.String(); got != tt.want {
+			t.Errorf("%s %q: got %s, want %s", tt.base, tt.segments, got, tt.want)
+		}
+	}
+}
+
+// TestBatchPartialFailure checks the exit code of a batch by how many of its
+// targets failed, and that each failed target is reported with its error
+func TestBatchPartialFailure(t *testing.T) {
+	page, err := os.ReadFile(filepath.Join("testdata", "asn-prefix-tables.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/AS64501" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(page)
+	}))
+	defer srv.Close()
+
+	good := query{Type: "asn", Value: "AS64501"}
+	missing := query{Type: "asn", Value: "AS64496"}
+	invalid := query{Type: "ip", Value: "1.1.1.999"}
+	tests := []struct {
+		name     string
+		queries  []query
+		wantCode int
+		// the error types of the failed targets, by value
+		wantErrors   map[string]string
+		wantPrefixes int
+	}{
+		{"all good", []query{good}, exitOK, map[string]string{}, 6},
+		{"good and failing", []query{missing, good, invalid}, exitPartial,
+			map[string]string{"AS64496": "status", "1.1.1.999": "query"}, 6},
+		{"all failing", []query{missing, invalid}, exitFailure,
+			map[string]string{"AS64496": "status", "1.1.1.999": "query"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, out := runArgs(t, tt.queries, true, "-base-url", srv.URL, "-retries", "0")
+			if code != tt.wantCode {
+				t.Errorf("exit code %d, want %d", code, tt.wantCode)
+			}
+
+			got := map[string]string{}
+			prefixes := 0
+			dec := json.NewDecoder(strings.NewReader(out))
+			for dec.More() {
+				var line json.RawMessage
+				if err := dec.Decode(&line); err != nil {
+					t.Fatalf("%v in %s", err, out)
+				}
+				var status queryStatus
+				if json.Unmarshal(line, &status) == nil && status.Status == "error" {
+					got[status.Value] = status.Error.Type
+					continue
+				}
+				var rows []ASNInfo
+				if err := json.Unmarshal(line, &rows); err != nil {
+					t.Fatalf("unexpected line %s", line)
+				}
+				prefixes += len(rows)
+			}
+			if !reflect.DeepEqual(got, tt.wantErrors) {
+				t.Errorf("got errors %v, want %v", got, tt.wantErrors)
+			}
+			// the good target keeps its result
+			if prefixes != tt.wantPrefixes {
+				t.Errorf("got %d prefixes, want %d", prefixes, tt.wantPrefixes)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// resultMeta describes where the result of a query came from and how fresh
+// its data is, printed with -meta. It also carries the raw table of the page
+// for -output both. Headers holds the -show-headers headers of the last
+// response for the page.
+type resultMeta struct {
+	Host        string            `json:"host,omitempty"`
+	URL         string            `json:"url,omitempty"`
+	File        string            `json:"file,omitempty"`
+	Cached      bool              `json:"cached,omitempty"`
+	Status      int               `json:"status,omitempty"`
+	DataUpdated string            `json:"data_updated,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+
+	rawTable [][]string
+}
+
+// bothResult is a result of -output both, the parsed result next to the raw
+// table it was parsed from
+type bothResult struct {
+	Parsed   interface{} `json:"parsed"`
+	RawTable [][]string  `json:"raw_table"`
+}
+
+// envelope wraps a result with the query it answers for -echo-query and its
+// meta for -meta
+type envelope struct {
+	Query   *query      `json:"query,omitempty"`
+	Meta    *resultMeta `json:"meta,omitempty"`
+	Results interface{} `json:"results"`
+}
+
+// metaKey is the context key of the meta of the running query
+type metaKey struct{}
+
+// withMeta returns a context collecting the meta of a query
+func withMeta(ctx context.Context) (context.Context, *resultMeta) {
+	meta := &resultMeta{}
+	return context.WithValue(ctx, metaKey{}, meta), meta
+}
+
+// metaFrom returns the meta collected for the query of ctx. Without one, the
+// returned meta is discarded, so callers can always fill it in.
+func metaFrom(ctx context.Context) *resultMeta {
+	if meta, ok := ctx.Value(metaKey{}).(*resultMeta); ok {
+		return meta
+	}
+	return &resultMeta{}
+}
+
+// singleRow returns the only row of a list of rows for -single, and any other
+// result, including a list of another length, as is
+func singleRow(data interface{}) interface{} {
+	v := reflect.ValueOf(data)
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct && v.Len() == 1 {
+		return v.Index(0).Interface()
+	}
+	return data
+}
+
+// withEnvelope wraps the result of q in its envelope with -meta or
+// -echo-query and returns it as is otherwise. With -single, a list of one row
+// is unwrapped first, and with -output both, the result is paired with its
+// raw table.
+func withEnvelope(q query, meta *resultMeta, data interface{}) interface{} {
+	if opts.single {
+		data = singleRow(data)
+	}
+	if opts.output == "both" {
+		data = bothResult{Parsed: data, RawTable: meta.rawTable}
+	}
+	if !opts.meta && !opts.echoQuery {
+		return data
+	}
+
+	env := envelope{Results: data}
+	if opts.echoQuery {
+		env.Query = &q
+	}
+	if opts.meta {
+		env.Meta = meta
+	}
+	return env
+}
+
+// responseHeaders returns the -show-headers headers of a response by their
+// canonical name, every header with *. The values of a header sent several
+// times are joined with commas.
+func responseHeaders(h http.Header) map[string]string {
+	headers := map[string]string{}
+	all := slices.Contains(opts.showHeaders, "*")
+	for name, values := range h {
+		if all || slices.ContainsFunc(opts.showHeaders, func(want string) bool {
+			return strings.EqualFold(want, name)
+		}) {
+			headers[name] = strings.Join(values, ", ")
+		}
+	}
+	return headers
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// fetchBuckets are the upper bounds in seconds of the fetch latency histogram
+var fetchBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metrics collects the Prometheus metrics exposed in server mode. The format
+// is written by hand so the CLI needs no client library.
+type metrics struct {
+	mu          sync.Mutex
+	requests    map[[2]string]uint64
+	fetchErrors map[string]uint64
+	fetchCounts []uint64
+	fetchSum    float64
+	fetchTotal  uint64
+	cacheHits   uint64
+}
+
+// recorder collects the metrics of the current run. It is nil unless -metrics
+// is set, and every method is a no-op on a nil receiver.
+var recorder *metrics
+
+// newMetrics returns an empty metrics collector
+func newMetrics() *metrics {
+	return &metrics{
+		requests:    map[[2]string]uint64{},
+		fetchErrors: map[string]uint64{},
+		fetchCounts: make([]uint64, len(fetchBuckets)),
+	}
+}
+
+// request counts an API request of the given query type and response status
+func (m *metrics) request(queryType string, status int) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[[2]string{queryType, fmt.Sprint(status)}]++
+}
+
+// fetch records the latency of a request to the BGP website
+func (m *metrics) fetch(d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	secs := d.Seconds()
+	for i, upper := range fetchBuckets {
+		if secs <= upper {
+			m.fetchCounts[i]++
+		}
+	}
+	m.fetchSum += secs
+	m.fetchTotal++
+}
+
+// fetchError counts a failed request to the BGP website by kind of error
+func (m *metrics) fetchError(kind string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fetchErrors[kind]++
+}
+
+// cacheHit counts a page served from the disk cache
+func (m *metrics) cacheHit() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cacheHits++
+}
+
+// sortedKeys returns the keys of a counter map in a stable order
+func sortedKeys[K [2]string | string](counters map[K]uint64) []K {
+	keys := make([]K, 0, len(counters))
+	for k := range counters {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+	return keys
+}
+
+// writeTo writes the metrics in the Prometheus text exposition format
+func (m *metrics) writeTo(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP hebgp_requests_total API requests served by query type and status.")
+	fmt.Fprintln(w, "# TYPE hebgp_requests_total counter")
+	for _, k := range sortedKeys(m.requests) {
+		fmt.Fprintf(w, "hebgp_requests_total{type=%q,code=%q} %d\n", k[0], k[1], m.requests[k])
+	}
+
+	fmt.Fprintln(w, "# HELP hebgp_fetch_duration_seconds Latency of requests to bgp.he.net.")
+	fmt.Fprintln(w, "# TYPE hebgp_fetch_duration_seconds histogram")
+	for i, upper := range fetchBuckets {
+		fmt.Fprintf(w, "hebgp_fetch_duration_seconds_bucket{le=\"%g\"} %d\n", upper, m.fetchCounts[i])
+	}
+	fmt.Fprintf(w, "hebgp_fetch_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.fetchTotal)
+	fmt.Fprintf(w, "hebgp_fetch_duration_seconds_sum %g\n", m.fetchSum)
+	fmt.Fprintf(w, "hebgp_fetch_duration_seconds_count %d\n", m.fetchTotal)
+
+	fmt.Fprintln(w, "# HELP hebgp_fetch_errors_total Failed requests to bgp.he.net by kind of error.")
+	fmt.Fprintln(w, "# TYPE hebgp_fetch_errors_total counter")
+	for _, k := range sortedKeys(m.fetchErrors) {
+		fmt.Fprintf(w, "hebgp_fetch_errors_total{kind=%q} %d\n", k, m.fetchErrors[k])
+	}
+
+	fmt.Fprintln(w, "# HELP hebgp_cache_hits_total Pages served from the disk cache.")
+	fmt.Fprintln(w, "# TYPE hebgp_cache_hits_total counter")
+	fmt.Fprintf(w, "hebgp_cache_hits_total %d\n", m.cacheHits)
+}
+
+// ServeHTTP implements http.Handler for the /metrics endpoint
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.writeTo(w)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net"
+	"slices"
+	"strings"
+)
+
+// prefixKey returns the normalized form of a prefix, so that differently
+// written forms of the same prefix are grouped together
+func prefixKey(prefix string) string {
+	if _, ipnet, err := net.ParseCIDR(prefix); err == nil {
+		return ipnet.String()
+	}
+	return prefix
+}
+
+// originASNs groups the rows of an IP or network block page by prefix and
+// returns the distinct ASNs announcing each prefix, in the order shown. row
+// returns the prefix and ASN of the ith of n rows.
+func originASNs(n int, row func(i int) (string, string)) map[string][]string {
+	origins := map[string][]string{}
+	for i := 0; i < n; i++ {
+		prefix, asn := row(i)
+		key, asn := prefixKey(prefix), strings.ToUpper(asn)
+		if prefix == "" || asn == "" || slices.Contains(origins[key], asn) {
+			continue
+		}
+		origins[key] = append(origins[key], asn)
+	}
+	return origins
+}
+
+// markMultiOrigin flags the announcements of an IP whose prefix is
+// announced by more than one AS, a possible hijack or a MOAS setup, listing
+// every origin in OriginASNs
+func markMultiOrigin(rows []IPInfo) {
+	origins := originASNs(len(rows), func(i int) (string, string) {
+		return rows[i].Network, rows[i].ASN
+	})
+	for i := range rows {
+		if asns := origins[prefixKey(rows[i].Network)]; len(asns) > 1 {
+			rows[i].MultiOrigin, rows[i].OriginASNs = true, asns
+		}
+	}
+}
+
+// markMultiOriginNET flags the rows of a network block page like
+// markMultiOrigin does for an IP
+func markMultiOriginNET(rows []NETInfo) {
+	origins := originASNs(len(rows), func(i int) (string, string) {
+		return rows[i].Network, rows[i].ASN
+	})
+	for i := range rows {
+		if asns := origins[prefixKey(rows[i].Network)]; len(asns) > 1 {
+			rows[i].MultiOrigin, rows[i].OriginASNs = true, asns
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// resultWriter writes each query result to the output in a given format.
+// Close flushes anything the format holds back until the end of the run.
+type resultWriter interface {
+	Write(q query, data interface{}) error
+	Close() error
+}
+
+// jsonWriter writes each result as a line of JSON, without its zero fields
+// with -compact and with the -rename field names
+type jsonWriter struct {
+	w io.Writer
+}
+
+// Write implements resultWriter
+func (j jsonWriter) Write(_ query, data interface{}) error {
+	jsonData, err := encodeJSON(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(j.w, string(jsonData))
+	return err
+}
+
+// Close implements resultWriter
+func (j jsonWriter) Close() error {
+	return nil
+}
+
+// gobWriter writes each result as a gob value, for Go consumers that decode
+// the stream with encoding/gob
+type gobWriter struct {
+	enc *gob.Encoder
+}
+
+// Write implements resultWriter
+func (g gobWriter) Write(_ query, data interface{}) error {
+	return g.enc.Encode(data)
+}
+
+// Close implements resultWriter
+func (g gobWriter) Close() error {
+	return nil
+}
+
+// outputFormats maps each -output format to the constructor of its writer
+var outputFormats = map[string]func(io.Writer) resultWriter{
+	"json": func(w io.Writer) resultWriter { return jsonWriter{w: w} },
+	// both pairs each parsed result with its raw table, see withEnvelope
+	"both": func(w io.Writer) resultWriter { return jsonWriter{w: w} },
+	"gob":  func(w io.Writer) resultWriter { return gobWriter{enc: gob.NewEncoder(w)} },
+	"prom": func(w io.Writer) resultWriter { return newPromWriter(w) },
+
+	"sections": func(w io.Writer) resultWriter { return sectionWriter{w: w} },
+	"geojson":  func(w io.Writer) resultWriter { return newGeoJSONWriter(w) },
+	"tsv":      func(w io.Writer) resultWriter { return &tsvWriter{w: w} },
+}
+
+// openDatabase opens the -db file for -output sqlite. It is only set in
+// builds with the sqlite tag, keeping the SQLite driver out of default builds.
+var openDatabase func(path string) (resultWriter, error)
+
+// output is where the results of the current run are written
+var output resultWriter = jsonWriter{w: os.Stdout}
+
+// openOutput sets up the result writer for the -output format, or the single
+// field of -get, writing to the -o file when set. -output sqlite writes to the
+// -db database instead, -interactive to a table view in the terminal and
+// -split-by to a file per query type in -out-dir. The output is
+// gzip-compressed with -gzip or when the -o file ends in .gz. The returned
+// function closes the writer and the file.
+func openOutput() (func() error, error) {
+	jsonOutput := opts.output == "json" || opts.output == "both"
+	if (opts.meta || opts.echoQuery) && (!jsonOutput || opts.get != "" || opts.interactive) {
+		return nil, errors.New("-meta and -echo-query only apply to -output json")
+	}
+	if len(opts.showHeaders) > 0 && !opts.meta {
+		return nil, errors.New("-show-headers needs -meta")
+	}
+	if opts.single && (!jsonOutput || opts.get != "" || opts.interactive) {
+		return nil, errors.New("-single only applies to -output json")
+	}
+	if opts.acl && (opts.output != "json" || opts.get != "" || opts.interactive ||
+		opts.meta || opts.echoQuery || opts.single) {
+		return nil, errors.New("-acl prints bare prefixes and cannot be combined with another -output, -get, -interactive, -meta, -echo-query or -single")
+	}
+	if opts.peerEdges && (opts.output != "json" || opts.get != "" || opts.interactive ||
+		opts.meta || opts.echoQuery || opts.single || opts.acl) {
+		return nil, errors.New("-peer-edges prints an edge per line and cannot be combined with another -output, -get, -interactive, -meta, -echo-query, -single or -acl")
+	}
+	if opts.bare && (opts.output != "json" || opts.get != "" || opts.interactive ||
+		opts.meta || opts.echoQuery || opts.single || opts.acl || opts.peerEdges) {
+		return nil, errors.New("-bare prints one flat JSON array and cannot be combined with another -output, -get, -interactive, -meta, -echo-query, -single, -acl or -peer-edges")
+	}
+	if opts.keyBy != "" && opts.keyBy != "input" {
+		return nil, fmt.Errorf("unsupported -key-by %q, only input is", opts.keyBy)
+	}
+	if opts.keyBy != "" && (!jsonOutput || opts.get != "" || opts.interactive ||
+		opts.single || opts.acl || opts.peerEdges || opts.bare) {
+		return nil, errors.New("-key-by prints one JSON object and cannot be combined with another -output, -get, -interactive, -single, -acl, -peer-edges or -bare")
+	}
+	if opts.output == "both" && (opts.get != "" || opts.interactive) {
+		return nil, errors.New("-output both cannot be combined with -get or -interactive")
+	}
+	if opts.output == "sqlite" {
+		if opts.gzip {
+			return nil, errors.New("-gzip does not apply to -output sqlite")
+		}
+		if openDatabase == nil {
+			return nil, errors.New("-output sqlite needs a build with -tags sqlite")
+		}
+		if opts.db == "" {
+			return nil, errors.New("-output sqlite needs a -db file")
+		}
+		var err error
+		output, err = openDatabase(opts.db)
+		if err != nil {
+			return nil, err
+		}
+		return output.Close, nil
+	}
+
+	if opts.interactive {
+		var err error
+		output, err = newInteractiveWriter()
+		if err != nil {
+			return nil, err
+		}
+		return output.Close, nil
+	}
+
+	newWriter, ok := outputFormats[opts.output]
+	if !ok {
+		return nil, fmt.Errorf("unsupported -output format %q", opts.output)
+	}
+	if opts.get != "" {
+		newWriter = func(w io.Writer) resultWriter { return getWriter{w: w, field: opts.get} }
+	}
+	if opts.acl {
+		newWriter = func(w io.Writer) resultWriter { return getWriter{w: w, field: "prefixes"} }
+	}
+
+	if opts.peerEdges {
+		newWriter = func(w io.Writer) resultWriter { return edgeWriter{w: w} }
+	}
+	if opts.bare {
+		newWriter = func(w io.Writer) resultWriter { return newBareWriter(w) }
+	}
+	if opts.keyBy != "" {
+		newWriter = func(w io.Writer) resultWriter { return newKeyedWriter(w) }
+	}
+	if opts.splitBy != "" || opts.outDir != "" {
+		var err error
+		output, err = newSplitWriter(newWriter)
+		if err != nil {
+			return nil, err
+		}
+		return output.Close, nil
+	}
+
+	var w io.Writer = os.Stdout
+	closeFile := func() error { return nil }
+	if opts.outFile != "" {
+		var err error
+		w, closeFile, err = createOutput(opts.outFile, opts.gzip)
+		if err != nil {
+			return nil, err
+		}
+	} else if opts.gzip {
+		w, closeFile = wrapGzip(w, nil)
+	}
+
+	output = newWriter(w)
+	return func() error {
+		return errors.Join(output.Close(), closeFile())
+	}, nil
+}
+
+// createOutput creates the output file at path, gzip-compressed when gz is set
+// or the name ends in .gz. The returned function closes the file.
+func createOutput(path string, gz bool) (io.Writer, func() error, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if gz || strings.HasSuffix(path, ".gz") {
+		w, closeFile := wrapGzip(f, f)
+		return w, closeFile, nil
+	}
+	return f, f.Close, nil
+}
+
+// wrapGzip compresses what is written to w. The returned function closes the
+// gzip writer, then f when it is not nil.
+func wrapGzip(w io.Writer, f *os.File) (io.Writer, func() error) {
+	gz := gzip.NewWriter(w)
+	return gz, func() error {
+		// the gzip writer must be closed before the file to flush its footer
+		err := gz.Close()
+		if f != nil {
+			err = errors.Join(err, f.Close())
+		}
+		return err
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// maxAutoParallel caps the workers -parallel auto picks, so that a machine
+// with many CPUs does not overwhelm the site
+const maxAutoParallel = 8
+
+// workersValue is the -parallel flag, a number of workers or auto, held as
+// 0, for autoParallel to pick one
+type workersValue int
+
+// String implements flag.Value
+func (w *workersValue) String() string {
+	if *w == 0 {
+		return "auto"
+	}
+	return strconv.Itoa(int(*w))
+}
+
+// Set implements flag.Value
+func (w *workersValue) Set(value string) error {
+	if value == "auto" {
+		*w = 0
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return errors.New("want a number or auto")
+	}
+	*w = workersValue(n)
+	return nil
+}
+
+// Type names the kind of value for -dump-flags
+func (w *workersValue) Type() string {
+	return "int"
+}
+
+// autoParallel returns the workers of -parallel auto: two per CPU, as the
+// queries mostly wait on the network, but no more than the -rate lets start
+// each second, since the others would only wait on the limiter, and at most
+// maxAutoParallel. -diff needs the queries performed one at a time.
+func autoParallel() int {
+	if opts.diff != "" {
+		return 1
+	}
+	n := 2 * runtime.NumCPU()
+	if opts.rate > 0 {
+		n = min(n, int(math.Ceil(opts.rate)))
+	}
+	return max(1, min(n, maxAutoParallel))
+}
+
+// recordedWrite is a result written by a query performed with -parallel
+type recordedWrite struct {
+	q    query
+	data interface{}
+}
+
+// recordedWriter holds the results written by a query performed with
+// -parallel until the query's turn to be printed, so that only one goroutine
+// ever writes to the output
+type recordedWriter struct {
+	writes []recordedWrite
+}
+
+// Write implements resultWriter
+func (r *recordedWriter) Write(q query, data interface{}) error {
+	r.writes = append(r.writes, recordedWrite{q: q, data: data})
+	return nil
+}
+
+// Close implements resultWriter
+func (r *recordedWriter) Close() error {
+	return nil
+}
+
+// replay writes the recorded results to the output
+func (r *recordedWriter) replay() error {
+	for _, w := range r.writes {
+		if err := output.Write(w.q, w.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryOutcome is the outcome of a query performed with -parallel: its index
+// among the queries, its error and the results it wrote
+type queryOutcome struct {
+	index int
+	err   error
+	out   *recordedWriter
+}
+
+// checkOrder validates -parallel and -order, picking the workers of
+// -parallel auto
+func checkOrder() error {
+	if opts.parallel == 0 {
+		opts.parallel = autoParallel()
+	}
+	if opts.parallel < 1 {
+		return fmt.Errorf("-parallel must be at least 1, or auto, got %d", opts.parallel)
+	}
+	if opts.order != "input" && opts.order != "completion" {
+		return fmt.Errorf("unsupported -order %q, only input and completion are", opts.order)
+	}
+	// a gob stream has no -echo-query to tell which query a value is from
+	if opts.order == "completion" && opts.output == "gob" {
+		return errors.New("-order completion cannot be combined with -output gob, whose values follow the order of the queries")
+	}
+	if opts.parallel > 1 && opts.diff != "" {
+		return fmt.Errorf("-diff cannot be combined with -parallel")
+	}
+	return nil
+}
+
+// nextQuery performs the queries one at a time, returning the index and
+// error of the next one, or false once they are all done
+type nextQuery func() (int, error, bool)
+
+// sequentialQueries performs each query when the previous one is handled
+func sequentialQueries(ctx context.Context, queries []query) nextQuery {
+	i := 0
+	return func() (int, error, bool) {
+		if i >= len(queries) {
+			return 0, nil, false
+		}
+		i++
+		return i - 1, queryAndPrint(ctx, queries[i-1], output), true
+	}
+}
+
+// parallelQueries performs the queries with -parallel workers, printing the
+// results of each query in turn, in the order of the queries with -order
+// input or as they complete with -order completion. In input order, the
+// results of queries completing ahead of a slow one are held in memory until
+// it completes. No query is started once ctx is done.
+func parallelQueries(ctx context.Context, queries []query) nextQuery {
+	jobs := make(chan int)
+	outcomes := make(chan queryOutcome, len(queries))
+	go func() {
+		defer close(jobs)
+		for i := range queries {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < opts.parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				out := &recordedWriter{}
+				err := queryAndPrint(ctx, queries[i], out)
+				outcomes <- queryOutcome{index: i, err: err, out: out}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	pending := map[int]queryOutcome{}
+	nextIndex := 0
+	return func() (int, error, bool) {
+		for {
+			o, ok := pending[nextIndex]
+			if !ok || opts.order == "completion" {
+				if o, ok = <-outcomes; !ok {
+					return 0, nil, false
+				}
+				if opts.order == "input" && o.index != nextIndex {
+					pending[o.index] = o
+					continue
+				}
+			}
+			delete(pending, o.index)
+			nextIndex++
+			if err := o.out.replay(); err != nil {
+				o.err = err
+			}
+			return o.index, o.err, true
+		}
+	}
+}
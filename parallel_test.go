@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestCheckOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		order   string
+		wantErr bool
+	}{
+		{"json input", "json", "input", false},
+		{"json completion", "json", "completion", false},
+		{"gob input", "gob", "input", false},
+		// the values of a gob stream are matched to the queries by position
+		{"gob completion", "gob", "completion", true},
+		{"unknown order", "json", "random", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(saved options) { opts = saved }(opts)
+			opts.parallel, opts.output, opts.order = 2, tt.output, tt.order
+			if err := checkOrder(); (err != nil) != tt.wantErr {
+				t.Errorf("checkOrder() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// relationshipPeer is the relationship of the ASes of the peer tables. The
+// site does not tell transit from peering there, so every edge is a peer.
+const relationshipPeer = "peer"
+
+// PeerEdge is an edge of the peering graph between an ASN and one of its
+// peers, for importing into a graph database. The names are only filled in
+// with -resolve-names.
+type PeerEdge struct {
+	FromASN      string `json:"from_asn"`
+	ToASN        string `json:"to_asn"`
+	Relationship string `json:"relationship"`
+	FromName     string `json:"from_name,omitempty"`
+	ToName       string `json:"to_name,omitempty"`
+}
+
+// queryPeers reads the IPv4 and IPv6 peer tables of an ASN page and returns
+// an edge per peer, in page order, a peer of both families yielding one
+// edge. With -resolve-names, the name of the ASN comes from the page header
+// and the names of the peers from their description, or from their own page
+// when it is empty.
+func queryPeers(ctx context.Context, doc *goquery.Document, q query) []PeerEdge {
+	from := strings.ToUpper(q.Value)
+	fromName := ""
+	if opts.resolveNames {
+		fromName, _ = asnHeader(doc, from)
+	}
+
+	edges := []PeerEdge{}
+	seen := map[string]bool{}
+	doc.Find("#table_peers4 tbody tr, #table_peers6 tbody tr").Each(func(i int, row *goquery.Selection) {
+		to := strings.ToUpper(cellText(row, 4, "peer"))
+		if _, ok := asNumber(to); !ok || seen[to] {
+			return
+		}
+		seen[to] = true
+
+		edge := PeerEdge{FromASN: from, ToASN: to, Relationship: relationshipPeer}
+		if opts.resolveNames {
+			edge.FromName = fromName
+			edge.ToName = cellText(row, 1, "description")
+			if edge.ToName == "" {
+				edge.ToName = resolveASN(ctx, to).name
+			}
+		}
+		edges = append(edges, edge)
+	})
+	return edges
+}
+
+// edgeWriter prints each edge of a result as a JSON object on a line of its
+// own, for streaming into a graph database import
+type edgeWriter struct {
+	w io.Writer
+}
+
+// Write implements resultWriter
+func (e edgeWriter) Write(_ query, data interface{}) error {
+	// skipped and failed queries have no edges
+	if _, ok := data.(queryStatus); ok {
+		return nil
+	}
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Slice {
+		return fmt.Errorf("-peer-edges: unexpected result %T", data)
+	}
+	for i := 0; i < v.Len(); i++ {
+		jsonData, err := encodeJSON(v.Index(i).Interface())
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(e.w, string(jsonData)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close implements resultWriter
+func (e edgeWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestQueryPeers(t *testing.T) {
+	defer func(saved options) { opts = saved }(opts)
+	tests := []struct {
+		resolveNames bool
+		want         []PeerEdge
+	}{
+		// AS64502 peers over both families and yields one edge
+		{false, []PeerEdge{
+			{FromASN: "AS64500", ToASN: "AS64501", Relationship: "peer"},
+			{FromASN: "AS64500", ToASN: "AS64502", Relationship: "peer"},
+			{FromASN: "AS64500", ToASN: "AS64503", Relationship: "peer"},
+		}},
+		// every peer has a description, so no page is fetched for its name
+		{true, []PeerEdge{
+			{FromASN: "AS64500", ToASN: "AS64501", Relationship: "peer",
+				FromName: "Example Networks", ToName: "Example Transit"},
+			{FromASN: "AS64500", ToASN: "AS64502", Relationship: "peer",
+				FromName: "Example Networks", ToName: "Example Exchange Member"},
+			{FromASN: "AS64500", ToASN: "AS64503", Relationship: "peer",
+				FromName: "Example Networks", ToName: "Example IPv6 Only"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("resolve names %v", tt.resolveNames), func(t *testing.T) {
+			opts = options{resolveNames: tt.resolveNames}
+			doc := loadFixture(t, "asn-peers.html")
+			checkResult(t, queryPeers(context.Background(), doc, query{Type: "asn", Value: "as64500"}), tt.want)
+		})
+	}
+}
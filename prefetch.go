@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// prefetch warms the page cache with the targets of the named file, read
+// like a batch file, before the server starts. The pages are fetched by
+// -parallel workers within the rate and per-host limits of the run, so
+// entries still fresh in the cache cost no request. A page that fails is
+// logged and left out, and the counts are logged once all are done.
+func prefetch(ctx context.Context, name string) error {
+	queries, err := readBatch(name)
+	if err != nil {
+		return fmt.Errorf("-prefetch: %w", err)
+	}
+	start := time.Now()
+
+	jobs := make(chan query)
+	go func() {
+		defer close(jobs)
+		for _, q := range queries {
+			select {
+			case jobs <- q:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var mu sync.Mutex
+	var warmed, failed int
+	var wg sync.WaitGroup
+	for w := 0; w < opts.parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for q := range jobs {
+				err := prefetchPage(ctx, q)
+				if err != nil {
+					log.Printf("-prefetch: %s %s: %v", q.Type, q.Value, err)
+				}
+				mu.Lock()
+				if err != nil {
+					failed++
+				} else {
+					warmed++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	log.Printf("-prefetch: warmed %d of %d pages, %d failed, in %s",
+		warmed, len(queries), failed, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// prefetchPage fetches the page of a query into the cache, bound by the
+// timeout of its type like the query itself
+func prefetchPage(ctx context.Context, q query) error {
+	ctx = withQueryTimeout(ctx, q.Type)
+	q, err := normalizeQuery(q)
+	if err != nil {
+		return err
+	}
+	_, err = fetchPage(ctx, q)
+	return err
+}
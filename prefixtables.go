@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// prefixTables selects every prefix table of an ASN page. Besides the
+// table_prefixes4 and table_prefixes6 tables of the prefixes an AS
+// originates, some pages list other prefixes, such as those it transits, in
+// tables of their own named table_prefixes4_transit and so on. Some pages
+// set the id on an element wrapping the table rather than on the table.
+const prefixTables = `[id^="table_prefixes"]`
+
+// defaultPrefixCategory is the category of the prefixes of the tables named
+// after their address family alone
+const defaultPrefixCategory = "originated"
+
+// prefixCategory returns the category of the prefixes of the table with the
+// given id, the part of the id after its address family, such as transit
+// for table_prefixes6_transit
+func prefixCategory(id string) string {
+	category := strings.TrimPrefix(id, "table_prefixes")
+	category = strings.TrimLeft(category, "46")
+	category = strings.ToLower(strings.Trim(category, "_-"))
+	if category == "" {
+		return defaultPrefixCategory
+	}
+	return category
+}
+
+// tableFamily returns the address family of the prefixes of the table with
+// the given id, 4 or 6, or 0 when the id names none
+func tableFamily(id string) int {
+	switch rest := strings.TrimPrefix(id, "table_prefixes"); {
+	case strings.HasPrefix(rest, "4"):
+		return 4
+	case strings.HasPrefix(rest, "6"):
+		return 6
+	}
+	return 0
+}
+
+// eachPrefixRow calls f with each row of the prefix tables of an ASN page,
+// in page order, along with the id of its table and the category
+func eachPrefixRow(doc *goquery.Document, f func(row *goquery.Selection, table, category string)) {
+	doc.Find(prefixTables).Each(func(i int, table *goquery.Selection) {
+		id, _ := table.Attr("id")
+		category := prefixCategory(id)
+		table.Find("tbody tr").Each(func(i int, row *goquery.Selection) {
+			f(row, id, category)
+		})
+	})
+}
+
+// matchedPrefixTables returns the prefix tables of an ASN page whose
+// category passes the -prefix-category filter, in page order
+func matchedPrefixTables(doc *goquery.Document) *goquery.Selection {
+	return doc.Find(prefixTables).FilterFunction(func(i int, table *goquery.Selection) bool {
+		id, _ := table.Attr("id")
+		return matchCategory(prefixCategory(id))
+	})
+}
+
+// matchCategory reports whether the category of an ASN prefix passes the
+// -prefix-category filter
+func matchCategory(category string) bool {
+	if len(opts.prefixCategory) == 0 {
+		return true
+	}
+	for _, c := range opts.prefixCategory {
+		if strings.EqualFold(c, category) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestPrefixCategory(t *testing.T) {
+	tests := []struct {
+		id, want string
+	}{
+		{"table_prefixes4", "originated"},
+		{"table_prefixes6", "originated"},
+		{"table_prefixes4_transit", "transit"},
+		{"table_prefixes6-Transit", "transit"},
+	}
+	for _, tt := range tests {
+		if got := prefixCategory(tt.id); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
+
+// TestPrefixCategoryModes checks that the modes reading the prefix tables
+// themselves keep to the -prefix-category tables
+func TestPrefixCategoryModes(t *testing.T) {
+	tests := []struct {
+		file       string
+		categories listValue
+		acl        []string
+		v4, v6     int
+		raw        string
+	}{
+		{"asn-prefix-tables.html", nil,
+			[]string{"192.0.2.0/24", "198.51.100.0/24", "203.0.113.0/24", "203.0.113.128/25",
+				"2001:db8::/32", "2001:db8:1000::/36"},
+			4, 2, "192.0.2.0/24"},
+		{"asn-prefix-tables.html", listValue{"transit"},
+			[]string{"203.0.113.0/24", "203.0.113.128/25", "2001:db8:1000::/36"},
+			2, 1, "203.0.113.0/24"},
+		{"asn-v6-transit.html", listValue{"originated"},
+			[]string{"2001:db8:6::/48"}, 0, 1, "2001:db8:6::/48"},
+		// the id is on an element wrapping the table
+		{"asn-header.html", nil,
+			[]string{"1.1.1.0/24", "104.16.0.0/13"}, 2, 0, "1.1.1.0/24"},
+	}
+	defer func(saved options) { opts = saved }(opts)
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %v", tt.file, tt.categories), func(t *testing.T) {
+			opts = options{prefixCategory: tt.categories}
+			doc := loadFixture(t, tt.file)
+			q := query{Type: "asn", Value: "AS64501"}
+
+			if got := queryACL(doc, q).Prefixes; !slices.Equal(got, tt.acl) {
+				t.Errorf("acl: got %q, want %q", got, tt.acl)
+			}
+			summary := querySummary(doc, q)
+			if summary.PrefixesV4 != tt.v4 || summary.PrefixesV6 != tt.v6 {
+				t.Errorf("summary: got %d and %d prefixes, want %d and %d",
+					summary.PrefixesV4, summary.PrefixesV6, tt.v4, tt.v6)
+			}
+			// the header, then the first row of the first kept table
+			raw := queryRawTable(doc, q)
+			var first string
+			if len(raw) > 1 {
+				first = raw[1][0]
+			}
+			if first != tt.raw {
+				t.Errorf("raw table: got first prefix %q, want %q", first, tt.raw)
+			}
+		})
+	}
+}
+
+func TestStrictPrefixTables(t *testing.T) {
+	tests := []struct {
+		file       string
+		categories listValue
+		wantErr    string
+	}{
+		{"asn-prefix-tables.html", nil, ""},
+		{"asn-header.html", nil, ""},
+		{"asn-v6-transit.html", nil, ""},
+		{"asn-v6-transit.html", listValue{"transit"}, ""},
+		{"asn-v6-transit.html", listValue{"customer"}, "strict-html: no prefix table on the asn page"},
+		{"ip-not-routed.html", nil, "strict-html: no prefix table on the asn page"},
+	}
+	defer func(saved options) { opts = saved }(opts)
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %v", tt.file, tt.categories), func(t *testing.T) {
+			opts = options{prefixCategory: tt.categories}
+			err := checkStrictHTML(loadFixture(t, tt.file), query{Type: "asn", Value: "AS64503"})
+			if got := fmt.Sprint(err); tt.wantErr == "" && err != nil || tt.wantErr != "" && got != tt.wantErr {
+				t.Errorf("got %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// promHelp describes each metric written by -output prom
+var promHelp = map[string]string{
+	"hebgp_asn_prefixes":          "Prefixes announced by the ASN by address family.",
+	"hebgp_asn_exchanges":         "Exchanges the ASN is present at.",
+	"hebgp_ip_announcements":      "Announcements covering the IP.",
+	"hebgp_ip_routed":             "Whether the IP is announced in BGP.",
+	"hebgp_net_announcements":     "Announcements of the network block.",
+	"hebgp_org_results":           "Organization search results by type.",
+	"hebgp_asn_graphs":            "Graph data URLs the ASN page links to.",
+	"hebgp_asn_peers":             "BGP peers observed for the ASN by address family.",
+	"hebgp_asn_cone_size":         "ASes in the customer cone of the ASN, when the site shows it.",
+	"hebgp_asn_downstreams":       "Downstream ASes of the ASN, when the site shows them.",
+	"hebgp_asn_irr_mismatches":    "Prefixes announced but not in the IRR, or the other way round.",
+	"hebgp_asn_rib_matched":       "Prefixes of the ASN also in the local RIB dump.",
+	"hebgp_asn_rib_mismatches":    "Prefixes of the ASN only on the site or only in the local RIB dump.",
+	"hebgp_asn_ipv4_addresses":    "IPv4 addresses announced by the ASN.",
+	"hebgp_asn_ipv6_48s":          "IPv6 space announced by the ASN in /48s.",
+	"hebgp_asn_ipv6_prefixes":     "IPv6 prefixes announced by the ASN.",
+	"hebgp_prefix_events":         "Events in the prefix history of the ASN or network block by kind.",
+	"hebgp_find_asn_matches":      "ASes matching the organization name.",
+	"hebgp_abuse_contact_present": "Whether an abuse contact is published.",
+	"hebgp_diff_rows":             "Rows changed since the saved result by kind of change.",
+	"hebgp_query_skipped":         "Queries skipped because the run stopped early.",
+	"hebgp_query_failed":          "Queries that failed, or found nothing with -only-errors.",
+}
+
+// promWriter renders the counts of each result in the Prometheus textfile
+// format read by node_exporter's textfile collector. The file must hold every
+// metric family in one block, so the samples are written on Close.
+type promWriter struct {
+	w       io.Writer
+	samples map[string][]string
+}
+
+// newPromWriter returns a prom writer to w
+func newPromWriter(w io.Writer) *promWriter {
+	return &promWriter{w: w, samples: map[string][]string{}}
+}
+
+// promEscape escapes a label value for the text format
+func promEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// add records a sample of the named metric with labels given as name, value
+// pairs
+func (p *promWriter) add(name string, value float64, labels ...string) {
+	var pairs []string
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], promEscape(labels[i+1])))
+	}
+	sample := fmt.Sprintf("%s{%s} %g", name, strings.Join(pairs, ","), value)
+	p.samples[name] = append(p.samples[name], sample)
+}
+
+// boolValue returns 1 for true and 0 for false
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// prefixFamily returns v6 for IPv6 prefixes and v4 otherwise
+func prefixFamily(prefix string) string {
+	if strings.Contains(prefix, ":") {
+		return "v6"
+	}
+	return "v4"
+}
+
+// Write implements resultWriter
+func (p *promWriter) Write(q query, data interface{}) error {
+	switch res := data.(type) {
+	case []ASNInfo:
+		counts := map[string]int{"v4": 0, "v6": 0}
+		for _, row := range res {
+			counts[prefixFamily(row.Prefix)]++
+		}
+		for _, family := range []string{"v4", "v6"} {
+			p.add("hebgp_asn_prefixes", float64(counts[family]), "asn", q.Value, "family", family)
+		}
+	case []IXInfo:
+		p.add("hebgp_asn_exchanges", float64(len(res)), "asn", q.Value)
+	case IPResult:
+		p.add("hebgp_ip_announcements", float64(len(res.Announcement)), "ip", q.Value)
+		if res.Routed != nil {
+			p.add("hebgp_ip_routed", boolValue(*res.Routed), "ip", q.Value)
+		}
+	case []NETInfo:
+		p.add("hebgp_net_announcements", float64(len(res)), "network", q.Value)
+	case []ORGInfo:
+		counts := map[string]int{}
+		for _, row := range res {
+			counts[row.Type]++
+		}
+		for kind, n := range counts {
+			p.add("hebgp_org_results", float64(n), "query", q.Value, "type", kind)
+		}
+	case []FoundASN:
+		p.add("hebgp_find_asn_matches", float64(len(res)), "query", q.Value)
+	case GraphInfo:
+		p.add("hebgp_asn_graphs", float64(len(res.Graphs)), "asn", res.ASN)
+	case ASNStats:
+		if res.PeersV4 != nil {
+			p.add("hebgp_asn_peers", float64(*res.PeersV4), "asn", res.ASN, "family", "v4")
+		}
+		if res.PeersV6 != nil {
+			p.add("hebgp_asn_peers", float64(*res.PeersV6), "asn", res.ASN, "family", "v6")
+		}
+		if res.ConeSize != nil {
+			p.add("hebgp_asn_cone_size", float64(*res.ConeSize), "asn", res.ASN)
+		}
+		if res.Downstreams != nil {
+			p.add("hebgp_asn_downstreams", float64(*res.Downstreams), "asn", res.ASN)
+		}
+	case ASNSummary:
+		p.add("hebgp_asn_prefixes", float64(res.PrefixesV4), "asn", res.ASN, "family", "v4")
+		p.add("hebgp_asn_prefixes", float64(res.PrefixesV6), "asn", res.ASN, "family", "v6")
+	case IRRDiff:
+		if res.Available {
+			p.add("hebgp_asn_irr_mismatches", float64(len(res.Unregistered)), "asn", res.ASN,
+				"kind", "announced_not_registered")
+			p.add("hebgp_asn_irr_mismatches", float64(len(res.Unannounced)), "asn", res.ASN,
+				"kind", "registered_not_announced")
+		}
+	case RIBDiff:
+		p.add("hebgp_asn_rib_matched", float64(res.Matched), "asn", res.ASN)
+		p.add("hebgp_asn_rib_mismatches", float64(len(res.SiteOnly)), "asn", res.ASN, "kind", "site_only")
+		p.add("hebgp_asn_rib_mismatches", float64(len(res.LocalOnly)), "asn", res.ASN, "kind", "local_only")
+	case ASNSpace:
+		p.add("hebgp_asn_ipv4_addresses", float64(res.TotalIPv4), "asn", res.ASN)
+		p.add("hebgp_asn_ipv6_48s", float64(res.IPv6Slash48s), "asn", res.ASN)
+		p.add("hebgp_asn_ipv6_prefixes", float64(res.IPv6Prefixes), "asn", res.ASN)
+	case []PrefixEvent:
+		counts := map[string]int{}
+		for _, event := range res {
+			counts[event.Event]++
+		}
+		for event, n := range counts {
+			p.add("hebgp_prefix_events", float64(n), "type", q.Type, "value", q.Value, "event", event)
+		}
+	case AbuseInfo:
+		p.add("hebgp_abuse_contact_present", boolValue(res.AbuseContact != nil), "asn", res.ASN)
+	case diffResult:
+		for change, n := range map[string]int{"added": len(res.Added),
+			"removed": len(res.Removed), "changed": len(res.Changed)} {
+			p.add("hebgp_diff_rows", float64(n), "type", q.Type, "value", q.Value, "change", change)
+		}
+	case queryStatus:
+		if res.Status == "skipped" {
+			p.add("hebgp_query_skipped", 1, "type", q.Type, "value", q.Value)
+		} else {
+			p.add("hebgp_query_failed", 1, "type", q.Type, "value", q.Value, "status", res.Status)
+		}
+	default:
+		return fmt.Errorf("-output prom does not support %T results", data)
+	}
+	return nil
+}
+
+// Close implements resultWriter, writing every metric family sorted by name
+func (p *promWriter) Close() error {
+	names := make([]string, 0, len(p.samples))
+	for name := range p.samples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		samples := p.samples[name]
+		sort.Strings(samples)
+		if _, err := fmt.Fprintf(p.w, "# HELP %s %s\n# TYPE %s gauge\n%s\n",
+			name, promHelp[name], name, strings.Join(samples, "\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
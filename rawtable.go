@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// rawTables holds the element holding the main table of each query type's
+// page. ASN pages use their first prefix table of the -prefix-category
+// categories holding rows, and organization searches the first table of the
+// page.
+var rawTables = map[string]string{
+	"ip":  "#ipinfo",
+	"net": "#netinfo",
+}
+
+// queryRawTable returns the main table of a page as rows of cell texts, with
+// the header row first when the table has one, and without mapping them to
+// fields. -select-table picks another table.
+func queryRawTable(doc *goquery.Document, q query) [][]string {
+	table, _ := selectTable(doc)
+	switch selector, ok := rawTables[q.Type]; {
+	case table != nil:
+	case q.Type == "asn":
+		tables := matchedPrefixTables(doc)
+		table = tables.Has("tbody tr").First()
+		if table.Length() == 0 {
+			table = tables.First()
+		}
+	case ok:
+		table = doc.Find(selector).First()
+	default:
+		table = doc.Find("table").First()
+	}
+	if !table.Is("table") {
+		table = table.Find("table").First()
+	}
+
+	rows := [][]string{}
+	cells := func(row *goquery.Selection, selector string) []string {
+		texts := []string{}
+		row.Find(selector).Each(func(i int, cell *goquery.Selection) {
+			texts = append(texts, strings.Join(strings.Fields(cell.Text()), " "))
+		})
+		return texts
+	}
+	if header := table.Find("thead tr").First(); header.Length() > 0 {
+		rows = append(rows, cells(header, "th, td"))
+	}
+	table.Find("tbody tr").Each(func(i int, row *goquery.Selection) {
+		rows = append(rows, cells(row, "td, th"))
+	})
+	return rows
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// registries maps the names the site and whois use for the regional internet
+// registries to their canonical form. National registries are folded into
+// the RIR they belong to.
+var registries = map[string]string{
+	"arin":     "ARIN",
+	"ripe":     "RIPE",
+	"ripe ncc": "RIPE",
+	"ripencc":  "RIPE",
+	"apnic":    "APNIC",
+	"jpnic":    "APNIC",
+	"krnic":    "APNIC",
+	"twnic":    "APNIC",
+	"cnnic":    "APNIC",
+	"idnic":    "APNIC",
+	"irinn":    "APNIC",
+	"vnnic":    "APNIC",
+	"lacnic":   "LACNIC",
+	"afrinic":  "AFRINIC",
+}
+
+// normalizeRegistry returns the canonical name of a registry, or an empty
+// string when the name is not a known registry
+func normalizeRegistry(name string) string {
+	return registries[strings.ToLower(strings.TrimSpace(name))]
+}
+
+// pageRegistry returns the registry of the prefix shown on an IP or network
+// page, taken from the source of its whois record. ARIN records carry no
+// source but are told apart by their NetRange. The registry is empty when the
+// page has no whois or it names no known registry.
+func pageRegistry(doc *goquery.Document) string {
+	whois := doc.Find("#whois pre")
+	if source := labelValue(whois, "source"); source != "" {
+		return normalizeRegistry(source)
+	}
+	if labelValue(whois, "NetRange") != "" {
+		return "ARIN"
+	}
+	return ""
+}
+
+// rowRegistry returns the registry of a table row from its registry column,
+// or the registry of the page when the table has no such column
+func rowRegistry(row *goquery.Selection, page string) string {
+	if col := headerIndex(row, "registry", "rir"); col >= 0 {
+		return normalizeRegistry(row.Find("td").Eq(col).Text())
+	}
+	return page
+}
+
+// registryValue is the -registry flag: a list of registries like listValue,
+// normalized and checked as they are given
+type registryValue []string
+
+// String implements flag.Value
+func (r *registryValue) String() string {
+	return strings.Join(*r, ",")
+}
+
+// Set implements flag.Value, rejecting unknown registries
+func (r *registryValue) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		name := normalizeRegistry(v)
+		if name == "" {
+			return fmt.Errorf("unknown registry %q, want one of arin, ripe, apnic, lacnic, afrinic", v)
+		}
+		*r = append(*r, name)
+	}
+	return nil
+}
+
+// Type names the kind of value for -dump-flags
+func (r *registryValue) Type() string {
+	return "list"
+}
+
+// matchRegistry reports whether a row's registry passes the -registry filter.
+// Rows without a registry never match a filter.
+func matchRegistry(registry string) bool {
+	if len(opts.registries) == 0 {
+		return true
+	}
+	for _, r := range opts.registries {
+		if registry == r {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// resultTypes holds a value of each type of result, whose JSON field names
+// -rename may change
+var resultTypes = []interface{}{IPResult{}, []NETInfo{}, []ASNInfo{}, []ORGInfo{},
+	[]IXInfo{}, []FoundASN{}, AbuseInfo{}, GraphInfo{}, ASNStats{}, ASNSummary{}, IRRDiff{}, RIBDiff{},
+	PrefixList{}, ASNSpace{}, []PeerEdge{}, []PrefixEvent{}, queryStatus{}}
+
+// resultFields returns the JSON field names of every type of result
+func resultFields() map[string]bool {
+	names := map[string]bool{}
+	for _, v := range resultTypes {
+		for name := range fieldNames(reflect.TypeOf(v)) {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// renameValue is a repeatable flag holding the output field renames given as
+// old=new
+type renameValue map[string]string
+
+// String implements flag.Value
+func (r *renameValue) String() string {
+	pairs := make([]string, 0, len(*r))
+	for old, name := range *r {
+		pairs = append(pairs, old+"="+name)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Set implements flag.Value, rejecting fields that do not exist and new names
+// that would clash with another field
+func (r *renameValue) Set(value string) error {
+	old, name, ok := strings.Cut(value, "=")
+	old, name = strings.TrimSpace(old), strings.TrimSpace(name)
+	if !ok || old == "" || name == "" {
+		return fmt.Errorf("rename must be old=new, got %q", value)
+	}
+
+	fields := resultFields()
+	if !fields[old] {
+		return fmt.Errorf("no output field %q to rename", old)
+	}
+	if fields[name] {
+		return fmt.Errorf("cannot rename %s to %s, which is already a field", old, name)
+	}
+	for o, n := range *r {
+		if n == name && o != old {
+			return fmt.Errorf("cannot rename both %s and %s to %s", o, old, name)
+		}
+	}
+
+	if *r == nil {
+		*r = renameValue{}
+	}
+	(*r)[old] = name
+	return nil
+}
+
+// renameJSON renames the object keys of a JSON document with the -rename
+// renames, at any depth and keeping the order of the fields
+func renameJSON(data []byte, renames map[string]string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var b bytes.Buffer
+	if err := renameTokens(dec, &b, renames); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// renameTokens copies the next value of dec to b, renaming the keys of the
+// objects it holds
+func renameTokens(dec *json.Decoder, b *bytes.Buffer, renames map[string]string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		// scalars marshal back to the same JSON, numbers kept as written
+		value, err := json.Marshal(tok)
+		b.Write(value)
+		return err
+	}
+
+	b.WriteRune(rune(delim))
+	for i := 0; dec.More(); i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if delim == '{' {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key := tok.(string)
+			if name, ok := renames[key]; ok {
+				key = name
+			}
+			quoted, _ := json.Marshal(key)
+			b.Write(quoted)
+			b.WriteByte(':')
+		}
+		if err := renameTokens(dec, b, renames); err != nil {
+			return err
+		}
+	}
+	end, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	b.WriteRune(rune(end.(json.Delim)))
+	return nil
+}
+
+// encodeJSON marshals a result as JSON, without its empty fields with -compact
+// and with the -rename field names
+func encodeJSON(data interface{}) ([]byte, error) {
+	jsonData, err := json.Marshal(data)
+	if err == nil && opts.compact {
+		jsonData, err = compactJSON(jsonData)
+	}
+	if err == nil && len(opts.renames) > 0 {
+		jsonData, err = renameJSON(jsonData, opts.renames)
+	}
+	return jsonData, err
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// asnHeader returns the AS name and registered country shown in the header of
+// an ASN page. The country is empty when the header shows no flag, or flags
+// of several countries.
+func asnHeader(doc *goquery.Document, asn string) (string, string) {
+	header := doc.Find("h1").First()
+	name := strings.Join(strings.Fields(header.Text()), " ")
+	if upper := strings.ToUpper(name); strings.HasPrefix(upper, strings.ToUpper(asn)) {
+		name = strings.TrimSpace(name[len(asn):])
+	}
+
+	country := ""
+	// the flag is in the heading or next to it, before the tables of the page
+	flags := header.NextUntil("table, div[id]").AddBack().Find(flagSelector)
+	flags.EachWithBreak(func(i int, img *goquery.Selection) bool {
+		code := flagCountry(img)
+		if code == "" {
+			return true
+		}
+		if country != "" && code != country {
+			country = ""
+			return false
+		}
+		country = code
+		return true
+	})
+	return name, country
+}
+
+// asnDetails is the name and country of an AS looked up for -resolve-names
+type asnDetails struct {
+	name    string
+	country string
+}
+
+// resolved holds the ASes looked up during the run, so each page is fetched
+// once however many results an AS originates. It starts out with the ASes of
+// the -asn-db file.
+var resolved = struct {
+	sync.Mutex
+	asns map[string]asnDetails
+}{asns: map[string]asnDetails{}}
+
+// resolveASN returns the name and country of an AS from its page. A failed
+// lookup is logged and leaves both empty.
+func resolveASN(ctx context.Context, asn string) asnDetails {
+	asn = strings.ToUpper(asn)
+	if asn == "" {
+		return asnDetails{}
+	}
+	resolved.Lock()
+	details, ok := resolved.asns[asn]
+	resolved.Unlock()
+	if ok {
+		return details
+	}
+
+	// the lookup is not the page of the query, keep it out of its meta
+	ctx, _ = withMeta(ctx)
+	doc, err := fetchPage(ctx, query{Type: "asn", Value: asn})
+	if err != nil {
+		log.Printf("-resolve-names: %s: %v", asn, err)
+		return details
+	}
+	details.name, details.country = asnHeader(doc, asn)
+
+	resolved.Lock()
+	resolved.asns[asn] = details
+	resolved.Unlock()
+	return details
+}
+
+// loadASNDB adds the ASes of an -asn-db file to the resolved ones, so that
+// only the ASes missing from it are looked up on the site. Each line holds an
+// AS number, with or without the AS prefix, its name and optionally its
+// country, separated by tabs. Blank lines and lines starting with '#' are
+// ignored.
+func loadASNDB(path string) error {
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("-asn-db: %w", err)
+	}
+	defer f.Close()
+
+	resolved.Lock()
+	defer resolved.Unlock()
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		asn := strings.ToUpper(strings.TrimSpace(fields[0]))
+		if !strings.HasPrefix(asn, "AS") {
+			asn = "AS" + asn
+		}
+		if _, ok := asNumber(asn); !ok || len(fields) < 2 {
+			return fmt.Errorf("-asn-db: %s:%d: want an AS number and a name separated by a tab", path, n)
+		}
+
+		details := asnDetails{name: strings.TrimSpace(fields[1])}
+		if len(fields) > 2 {
+			details.country = strings.ToUpper(strings.TrimSpace(fields[2]))
+		}
+		resolved.asns[asn] = details
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("-asn-db: %w", err)
+	}
+	return nil
+}
+
+// resolveNames fills in the name and country of the origin AS of the rows of
+// IP and network results, from the page of each AS
+func resolveNames(ctx context.Context, data interface{}) (interface{}, error) {
+	if opts.htmlFile != "" {
+		return nil, errors.New("-resolve-names needs to fetch the AS pages, not -html-file")
+	}
+
+	switch res := data.(type) {
+	case IPResult:
+		for i, row := range res.Announcement {
+			details := resolveASN(ctx, row.ASN)
+			if row.ASNName == "" {
+				res.Announcement[i].ASNName = details.name
+			}
+			res.Announcement[i].ASNCountry = details.country
+		}
+		return res, nil
+	case []NETInfo:
+		for i, row := range res {
+			details := resolveASN(ctx, row.ASN)
+			res[i].ASNName = details.name
+			res[i].ASNCountry = details.country
+		}
+		return res, nil
+	}
+	return data, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// RIBDiff compares the prefixes an ASN announces according to the BGP website
+// with those it originates in a local RIB dump, loaded with -rib
+type RIBDiff struct {
+	ASN       string   `json:"asn"`
+	Matched   int      `json:"matched"`
+	SiteOnly  []string `json:"site_only"`
+	LocalOnly []string `json:"local_only"`
+}
+
+// rib holds the prefixes of the -rib file by origin ASN, loaded once for the
+// whole run. It is nil without -rib.
+var rib map[string]map[netip.Prefix]bool
+
+// loadRIB reads the prefixes and origin ASNs of a -rib file. Each line holds
+// either a prefix and the ASN originating it, separated by white space, such
+// as "192.0.2.0/24 AS64500", or an entry in the one-line format of
+// "bgpdump -m", so an MRT dump can be piped through bgpdump. The origin of a
+// bgpdump entry is the last AS of its path, and entries ending in an AS set
+// are skipped since their origin is ambiguous. Blank lines and lines starting
+// with '#' are ignored. Prefixes are normalized and kept once per origin,
+// however many peers the dump saw them from.
+func loadRIB(path string) error {
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("-rib: %w", err)
+	}
+	defer f.Close()
+
+	rib = map[string]map[netip.Prefix]bool{}
+	scanner := bufio.NewScanner(f)
+	// AS paths of bgpdump entries can be long
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		prefix, origin, ok := ribEntry(line)
+		if !ok {
+			return fmt.Errorf("-rib: %s:%d: want a prefix and an origin ASN, or a bgpdump -m entry", path, n)
+		}
+		if origin == "" {
+			continue
+		}
+		if rib[origin] == nil {
+			rib[origin] = map[netip.Prefix]bool{}
+		}
+		rib[origin][prefix] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("-rib: %w", err)
+	}
+	return nil
+}
+
+// ribEntry parses a line of a -rib file into its normalized prefix and origin
+// ASN. The origin is empty for a bgpdump entry ending in an AS set.
+func ribEntry(line string) (netip.Prefix, string, bool) {
+	var prefix, origin string
+	if fields := strings.Split(line, "|"); len(fields) > 6 {
+		// TABLE_DUMP2|time|B|peer IP|peer AS|prefix|AS path|...
+		path := strings.Fields(fields[6])
+		if len(path) == 0 {
+			return netip.Prefix{}, "", false
+		}
+		prefix, origin = fields[5], path[len(path)-1]
+		if strings.HasPrefix(origin, "{") {
+			p, err := netip.ParsePrefix(prefix)
+			return p.Masked(), "", err == nil
+		}
+	} else if fields := strings.Fields(line); len(fields) == 2 {
+		prefix, origin = fields[0], fields[1]
+	} else {
+		return netip.Prefix{}, "", false
+	}
+
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return netip.Prefix{}, "", false
+	}
+	origin = strings.ToUpper(origin)
+	if !strings.HasPrefix(origin, "AS") {
+		origin = "AS" + origin
+	}
+	if _, ok := asNumber(origin); !ok {
+		return netip.Prefix{}, "", false
+	}
+	return p.Masked(), origin, true
+}
+
+// queryRIB diffs the prefixes announced by an ASN on its page against those
+// the -rib file has it originate. The prefix length filters apply to both.
+func queryRIB(doc *goquery.Document, q query) RIBDiff {
+	diff := RIBDiff{ASN: strings.ToUpper(q.Value), SiteOnly: []string{}, LocalOnly: []string{}}
+	site := map[netip.Prefix]bool{}
+	var siteOnly, localOnly []netip.Prefix
+	for _, prefix := range asnPrefixes(doc, 0) {
+		site[prefix] = true
+		if rib[diff.ASN][prefix] {
+			diff.Matched++
+		} else {
+			siteOnly = append(siteOnly, prefix)
+		}
+	}
+	for prefix := range rib[diff.ASN] {
+		if !site[prefix] && matchPrefixLen(prefix.String()) {
+			localOnly = append(localOnly, prefix)
+		}
+	}
+
+	sortPrefixes(siteOnly)
+	sortPrefixes(localOnly)
+	for _, prefix := range siteOnly {
+		diff.SiteOnly = append(diff.SiteOnly, prefix.String())
+	}
+	for _, prefix := range localOnly {
+		diff.LocalOnly = append(diff.LocalOnly, prefix.String())
+	}
+	return diff
+}
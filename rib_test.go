@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestQueryRIB(t *testing.T) {
+	defer func(saved map[string]map[netip.Prefix]bool) { rib = saved }(rib)
+	if err := loadRIB("testdata/rib.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		asn  string
+		want RIBDiff
+	}{
+		// 203.0.113.0/24 comes from the bgpdump entries, the /48 of an AS
+		// set has no single origin and is left out
+		{"AS64500", RIBDiff{ASN: "AS64500", Matched: 5,
+			SiteOnly:  []string{"192.0.2.64/26", "2001:db8:8000::/33", "2001:db8:ffff::/48"},
+			LocalOnly: []string{"198.18.0.0/15"}}},
+		{"as64501", RIBDiff{ASN: "AS64501", Matched: 1,
+			SiteOnly: []string{"192.0.2.0/24", "192.0.2.64/26", "198.51.100.0/25", "198.51.100.128/25",
+				"2001:db8::/33", "2001:db8:8000::/33", "2001:db8:ffff::/48"},
+			LocalOnly: []string{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.asn, func(t *testing.T) {
+			checkResult(t, queryRIB(loadFixture(t, "asn-acl.html"), query{Type: "asn", Value: tt.asn}), tt.want)
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// sectionWriter prints each result as labeled sections for reading in a
+// terminal: the single value fields of the result first, then a table per
+// list of rows, such as the announcements and DNS records of an IP, and the
+// text of fields such as the whois. Each section is written out as soon as
+// it is laid out.
+type sectionWriter struct {
+	w io.Writer
+}
+
+// Write implements resultWriter
+func (s sectionWriter) Write(q query, data interface{}) error {
+	v := reflect.ValueOf(data)
+	title := strings.TrimSpace(q.Type + " " + q.Value)
+
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct {
+		return s.section(title, func(w io.Writer) { writeRows(w, v) })
+	}
+	if v.Kind() != reflect.Struct {
+		return s.section(title, func(w io.Writer) { fmt.Fprintln(w, data) })
+	}
+
+	// the single value fields come first, under the title of the query,
+	// and the fields spanning lines get a section of their own
+	err := s.section(title, func(w io.Writer) {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Field(i)
+			if !scalarField(field.Type()) {
+				continue
+			}
+			value := formatValue(field)
+			if strings.Contains(value, "\n") {
+				continue
+			}
+			fmt.Fprintf(tw, "%s:\t%s\n", jsonName(v.Type().Field(i)), value)
+		}
+		tw.Flush()
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		name := sectionTitle(jsonName(v.Type().Field(i)))
+		switch {
+		case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Struct:
+			err = s.section(name, func(w io.Writer) { writeRows(w, field) })
+		case field.Kind() == reflect.Slice:
+			err = s.section(name, func(w io.Writer) {
+				for j := 0; j < field.Len(); j++ {
+					fmt.Fprintln(w, formatValue(field.Index(j)))
+				}
+			})
+		case field.Kind() == reflect.Map:
+			for _, key := range mapKeys(field) {
+				err = s.section(name+": "+key, func(w io.Writer) {
+					fmt.Fprintln(w, formatValue(field.MapIndex(reflect.ValueOf(key))))
+				})
+				if err != nil {
+					break
+				}
+			}
+		case scalarField(field.Type()) && strings.Contains(formatValue(field), "\n"):
+			err = s.section(name, func(w io.Writer) { fmt.Fprintln(w, formatValue(field)) })
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close implements resultWriter
+func (s sectionWriter) Close() error {
+	return nil
+}
+
+// section writes a section headed by its title, laid out by body, and a blank
+// line closing it
+func (s sectionWriter) section(title string, body func(io.Writer)) error {
+	b := bufio.NewWriter(s.w)
+	fmt.Fprintf(b, "== %s ==\n", title)
+	body(b)
+	fmt.Fprintln(b)
+	return b.Flush()
+}
+
+// writeRows writes a list of rows as a table aligned in columns, or (none)
+// when there are no rows
+func writeRows(w io.Writer, v reflect.Value) {
+	if v.Len() == 0 {
+		fmt.Fprintln(w, "(none)")
+		return
+	}
+	header, rows := structRows(v)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	tw.Flush()
+}
+
+// acronyms are the words of field names written in capitals in section
+// titles
+var acronyms = map[string]bool{"asn": true, "dns": true, "ip": true, "irr": true, "ix": true}
+
+// sectionTitle turns the JSON name of a field into the title of its section,
+// such as Whois Sources for whois_sources and DNS for dns
+func sectionTitle(name string) string {
+	words := strings.Split(name, "_")
+	for i, word := range words {
+		switch {
+		case acronyms[word]:
+			words[i] = strings.ToUpper(word)
+		case word != "":
+			words[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// mapKeys returns the string keys of a map in order
+func mapKeys(m reflect.Value) []string {
+	keys := make([]string, 0, m.Len())
+	for _, key := range m.MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// shutdownTimeout is how long in-flight requests get to finish on shutdown
+const shutdownTimeout = 10 * time.Second
+
+// serve runs the queries as a JSON API on addr until interrupted and returns
+// the exit code. All requests share the HTTP client and rate limiter.
+func serve(addr string) int {
+	if err := checkModes(); err != nil {
+		log.Print(err)
+		return exitUsage
+	}
+	if err := checkPrefixLens(); err != nil {
+		log.Print(err)
+		return exitUsage
+	}
+	if err := setupClient(); err != nil {
+		log.Print(err)
+		return exitUsage
+	}
+	if opts.prefetch != "" && opts.cacheDir == "" {
+		log.Print("-prefetch needs a -cache-dir")
+		return exitUsage
+	}
+	if err := checkOrder(); err != nil {
+		log.Print(err)
+		return exitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if opts.prefetch != "" {
+		if err := prefetch(ctx, opts.prefetch); err != nil {
+			log.Print(err)
+			return exitUsage
+		}
+		if ctx.Err() != nil {
+			return exitOK
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("GET /asn/{value}", queryHandler("asn"))
+	mux.HandleFunc("GET /ip/{value}", queryHandler("ip"))
+	// network blocks contain a slash, so the value spans the rest of the path
+	mux.HandleFunc("GET /net/{value...}", queryHandler("net"))
+	mux.HandleFunc("GET /org/{value}", queryHandler("org"))
+	mux.HandleFunc("GET /find-asn/{value}", queryHandler("find-asn"))
+	if opts.metrics {
+		recorder = newMetrics()
+		mux.Handle("GET /metrics", recorder)
+	}
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		log.Printf("serving on %s", addr)
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		log.Print(err)
+		return exitFailure
+	case <-ctx.Done():
+	}
+
+	log.Print("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Print(err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// queryHandler returns the handler running queries of the given type on the
+// value in the request path
+func queryHandler(queryType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := query{Type: queryType, Value: r.PathValue("value")}
+		if q.Value == "" {
+			recorder.request(queryType, http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, errors.New("missing query value"))
+			return
+		}
+
+		ctx, meta := withMeta(r.Context())
+		data, err := runQueryRetrying(ctx, q)
+		if err != nil {
+			log.Printf("%s %s: %v", q.Type, q.Value, err)
+			recorder.request(queryType, http.StatusBadGateway)
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		recorder.request(queryType, http.StatusOK)
+		writeResponse(w, http.StatusOK, withEnvelope(q, meta, data))
+	}
+}
+
+// writeResponse writes data as a JSON response with the given status, encoded
+// like the command line output with -compact and -rename
+func writeResponse(w http.ResponseWriter, status int, data interface{}) {
+	jsonData, err := encodeJSON(data)
+	if err != nil {
+		log.Print(err)
+		status, jsonData = http.StatusInternalServerError, []byte(`{"error":"cannot encode the result"}`)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(append(jsonData, '\n')); err != nil {
+		log.Print(err)
+	}
+}
+
+// writeError writes err as a JSON error response with the given status
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeResponse(w, status, map[string]string{"error": err.Error()})
+}
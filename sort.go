@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// asNumber returns the number of an AS number such as AS13335, reporting
+// whether the value is one
+func asNumber(s string) (uint64, bool) {
+	if len(s) < 3 || !strings.EqualFold(s[:2], "AS") {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s[2:], 10, 32)
+	return n, err == nil
+}
+
+// isNumber reports whether a cell is a number
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+// columnOrder returns the order of the cells of a column, picked once for
+// the whole column so that every pair of cells compares the same way: by AS
+// number when every cell is one, so that AS2 comes before AS10 and AS1000,
+// numerically when every cell is a number, and as text otherwise. Empty
+// cells do not count and come first.
+func columnOrder(cells []string) func(a, b string) bool {
+	as, numbers := true, true
+	for _, cell := range cells {
+		if cell == "" {
+			continue
+		}
+		_, ok := asNumber(cell)
+		as = as && ok
+		numbers = numbers && isNumber(cell)
+	}
+
+	var key func(string) float64
+	switch {
+	case as:
+		key = func(s string) float64 { n, _ := asNumber(s); return float64(n) }
+	case numbers:
+		key = func(s string) float64 { f, _ := strconv.ParseFloat(s, 64); return f }
+	default:
+		return func(a, b string) bool { return a < b }
+	}
+	return func(a, b string) bool {
+		if a == "" || b == "" {
+			return a == "" && b != ""
+		}
+		return key(a) < key(b)
+	}
+}
+
+// fieldIndex returns the index of the field of a struct type with the given
+// JSON name, or -1 when there is none
+func fieldIndex(t reflect.Type, name string) int {
+	for i := 0; i < t.NumField(); i++ {
+		if jsonName(t.Field(i)) == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// sortSlice orders a slice of structs by the named field, keeping the order
+// of equal rows. It reports whether the rows have the field.
+func sortSlice(v reflect.Value, name string) bool {
+	field := fieldIndex(v.Type().Elem(), name)
+	if field < 0 {
+		return false
+	}
+	cells := make([]string, v.Len())
+	for i := range cells {
+		cells[i] = formatValue(v.Index(i).Field(field))
+	}
+	less := columnOrder(cells)
+	sort.SliceStable(v.Interface(), func(i, j int) bool {
+		return less(formatValue(v.Index(i).Field(field)), formatValue(v.Index(j).Field(field)))
+	})
+	return true
+}
+
+// rowFieldNames returns the JSON names of the fields -sort can order a
+// result of the type by: those of its rows, or of the rows of each of its
+// lists, but not the fields of the result itself such as the ip of an IP
+// result
+func rowFieldNames(t reflect.Type) map[string]bool {
+	names := map[string]bool{}
+	switch {
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct:
+		for i := 0; i < t.Elem().NumField(); i++ {
+			names[jsonName(t.Elem().Field(i))] = true
+		}
+	case t.Kind() == reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			for name := range rowFieldNames(t.Field(i).Type) {
+				names[name] = true
+			}
+		}
+	}
+	return names
+}
+
+// sortResult orders the rows of a result by the -sort field: the rows of a
+// list, or of each list in a result such as the announcements and DNS
+// records of an IP that have the field
+func sortResult(data interface{}) (interface{}, error) {
+	v := reflect.ValueOf(data)
+	sorted := false
+	switch {
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct:
+		sorted = sortSlice(v, opts.sort)
+	case v.Kind() == reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Field(i)
+			if field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Struct {
+				sorted = sortSlice(field, opts.sort) || sorted
+			}
+		}
+	}
+	if !sorted {
+		valid := []string{}
+		for name := range rowFieldNames(v.Type()) {
+			valid = append(valid, name)
+		}
+		sort.Strings(valid)
+		return nil, fmt.Errorf("unknown -sort field %q, valid fields: %s",
+			opts.sort, strings.Join(valid, ", "))
+	}
+	return data, nil
+}
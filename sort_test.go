@@ -0,0 +1,68 @@
+package main
+
+import (
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestColumnOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		cells []string
+		want  []string
+	}{
+		{"AS numbers", []string{"AS1000", "AS2", "as10", "AS1"}, []string{"AS1", "AS2", "as10", "AS1000"}},
+		{"numbers", []string{"10", "9", "1.5", "-3"}, []string{"-3", "1.5", "9", "10"}},
+		{"empty cells first", []string{"AS10", "", "AS2", ""}, []string{"", "", "AS2", "AS10"}},
+		// one cell that is not an AS number sorts the whole column as text
+		{"AS numbers and text", []string{"AS10", "AS2", "unknown", "AS1000"},
+			[]string{"AS10", "AS1000", "AS2", "unknown"}},
+		{"numbers, AS numbers and text", []string{"10", "AS2", "9", "x", "AS10"},
+			[]string{"10", "9", "AS10", "AS2", "x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := slices.Clone(tt.cells)
+			sort.SliceStable(got, func(i, j int) bool { return columnOrder(tt.cells)(got[i], got[j]) })
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortResult(t *testing.T) {
+	defer func(saved options) { opts = saved }(opts)
+	opts = options{sort: "asn"}
+
+	res := IPResult{IP: "192.0.2.1", Announcement: []IPInfo{
+		{ASN: "AS1000", Network: "192.0.2.0/24"},
+		{ASN: "AS2", Network: "192.0.2.0/23"},
+		{ASN: "AS10", Network: "192.0.0.0/16"},
+	}}
+	got, err := sortResult(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var asns []string
+	for _, row := range got.(IPResult).Announcement {
+		asns = append(asns, row.ASN)
+	}
+	if want := []string{"AS2", "AS10", "AS1000"}; !slices.Equal(asns, want) {
+		t.Errorf("got %q, want %q", asns, want)
+	}
+}
+
+func TestSortResultUnknownField(t *testing.T) {
+	defer func(saved options) { opts = saved }(opts)
+	opts = options{sort: "whois"}
+
+	// whois is a field of the result itself, not of the rows it holds
+	_, err := sortResult(IPResult{IP: "192.0.2.1"})
+	want := `unknown -sort field "whois", valid fields: a_records, as_path, asn, asn_country, asn_name, country, description, ` +
+		`ip, malformed, multi_origin, network, origin, origin_asns, ptr, registry, rpki, url`
+	if err == nil || err.Error() != want {
+		t.Errorf("got %v, want %s", err, want)
+	}
+}
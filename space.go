@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// ASNSpace is the address space announced by an ASN. IPv6 space is counted
+// in /48s, the longest prefix routed on the internet, since its address
+// count does not fit in a number; a longer prefix counts as one /48. The
+// IPv6 prefixes themselves are counted apart.
+type ASNSpace struct {
+	ASN          string `json:"asn"`
+	TotalIPv4    uint64 `json:"total_ipv4"`
+	IPv6Slash48s uint64 `json:"total_ipv6_48s"`
+	IPv6Prefixes int    `json:"total_ipv6_prefixes"`
+	Aggregated   bool   `json:"aggregated"`
+}
+
+// querySpace sums the addresses of the IPv4 prefixes and the /48s of the
+// IPv6 prefixes announced by an ASN, and counts the IPv6 prefixes.
+// Overlapping prefixes are counted once each unless -aggregate merges them
+// first, which Aggregated records.
+func querySpace(doc *goquery.Document, q query) ASNSpace {
+	space := ASNSpace{ASN: strings.ToUpper(q.Value), Aggregated: opts.aggregate}
+	for _, prefix := range asnPrefixes(doc, 0) {
+		switch {
+		case prefix.Addr().Is4():
+			space.TotalIPv4 += 1 << (32 - prefix.Bits())
+			continue
+		case prefix.Bits() <= 48:
+			space.IPv6Slash48s += 1 << (48 - prefix.Bits())
+		default:
+			space.IPv6Slash48s++
+		}
+		space.IPv6Prefixes++
+	}
+	return space
+}
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestQuerySpace(t *testing.T) {
+	defer func(saved options) { opts = saved }(opts)
+	tests := []struct {
+		aggregate bool
+		want      ASNSpace
+	}{
+		// two /33s and a /48 inside them: 2 * 32768 /48s and one more, over
+		// three prefixes
+		{false, ASNSpace{ASN: "AS64500", TotalIPv4: 832, IPv6Slash48s: 65537, IPv6Prefixes: 3}},
+		{true, ASNSpace{ASN: "AS64500", TotalIPv4: 768, IPv6Slash48s: 65536, IPv6Prefixes: 1, Aggregated: true}},
+	}
+	for _, tt := range tests {
+		opts = options{aggregate: tt.aggregate}
+		checkResult(t, querySpace(loadFixture(t, "asn-acl.html"), query{Type: "asn", Value: "as64500"}), tt.want)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// splitFile is the output file of one query type
+type splitFile struct {
+	name  string
+	w     resultWriter
+	close func() error
+}
+
+// splitWriter writes the results of each query type to a file of its own in
+// -out-dir, such as ip.json and asn.json, created on the first result of the
+// type
+type splitWriter struct {
+	dir       string
+	ext       string
+	newWriter func(io.Writer) resultWriter
+	files     map[string]*splitFile
+}
+
+// newSplitWriter returns the writer of -split-by, creating -out-dir when
+// needed. newWriter makes the writer of each file.
+func newSplitWriter(newWriter func(io.Writer) resultWriter) (resultWriter, error) {
+	if opts.splitBy == "" {
+		return nil, errors.New("-out-dir needs -split-by")
+	}
+	if opts.splitBy != "type" {
+		return nil, fmt.Errorf("unsupported -split-by %q, only type is", opts.splitBy)
+	}
+	if opts.outDir == "" {
+		return nil, errors.New("-split-by needs an -out-dir")
+	}
+	if opts.outFile != "" {
+		return nil, errors.New("-split-by cannot be combined with -o")
+	}
+	if err := os.MkdirAll(opts.outDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	// files are named after the query type and the -output format
+	ext := opts.output
+	if ext == "both" {
+		ext = "json"
+	}
+	if opts.get != "" || opts.acl || ext == "sections" {
+		ext = "txt"
+	}
+	if opts.peerEdges {
+		ext = "jsonl"
+	}
+	if opts.gzip {
+		ext += ".gz"
+	}
+	return &splitWriter{dir: opts.outDir, ext: ext, newWriter: newWriter,
+		files: map[string]*splitFile{}}, nil
+}
+
+// Write implements resultWriter. An error names the file it happened on, the
+// files of the other query types are still written.
+func (s *splitWriter) Write(q query, data interface{}) error {
+	f, ok := s.files[q.Type]
+	if !ok {
+		name := filepath.Join(s.dir, q.Type+"."+s.ext)
+		w, closeFile, err := createOutput(name, opts.gzip)
+		if err != nil {
+			return err
+		}
+		f = &splitFile{name: name, w: s.newWriter(w), close: closeFile}
+		s.files[q.Type] = f
+	}
+
+	if err := f.w.Write(q, data); err != nil {
+		return fmt.Errorf("%s: %w", f.name, err)
+	}
+	return nil
+}
+
+// Close implements resultWriter, closing every file
+func (s *splitWriter) Close() error {
+	var errs []error
+	for _, f := range s.files {
+		if err := errors.Join(f.w.Close(), f.close()); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,233 @@
+//go:build sqlite
+
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func init() {
+	openDatabase = openSQLite
+}
+
+// sqlTable describes a table of -output sqlite. Rows are keyed on their
+// natural key, so a lookup done again replaces the stored row.
+type sqlTable struct {
+	columns []string
+	key     []string
+}
+
+// sqlTables holds the tables for each kind of result
+var sqlTables = map[string]sqlTable{
+	"asn_prefixes": {
+		columns: []string{"asn", "prefix", "description", "country", "rpki"},
+		key:     []string{"asn", "prefix"},
+	},
+	"ips": {
+		columns: []string{"ip", "routed", "whois"},
+		key:     []string{"ip"},
+	},
+	"ip_announcements": {
+		columns: []string{"ip", "asn", "asn_name", "network", "description",
+			"country", "rpki", "origin", "as_path", "registry"},
+		key: []string{"ip", "asn", "network"},
+	},
+	"ip_dns": {
+		columns: []string{"ip", "record_ip", "ptr", "a_records"},
+		key:     []string{"ip", "record_ip", "ptr"},
+	},
+	"networks": {
+		columns: []string{"query", "asn", "network", "description", "country",
+			"rpki", "registry"},
+		key: []string{"query", "asn", "network"},
+	},
+	"orgs": {
+		columns: []string{"query", "result", "type", "raw_type", "description", "country"},
+		key:     []string{"query", "result"},
+	},
+	"asn_graphs": {
+		columns: []string{"asn", "url"},
+		key:     []string{"asn", "url"},
+	},
+	"asn_stats": {
+		columns: []string{"asn", "peers_v4", "peers_v6"},
+		key:     []string{"asn"},
+	},
+	"asn_cones": {
+		columns: []string{"asn", "cone_size", "downstreams"},
+		key:     []string{"asn"},
+	},
+	"asn_summaries": {
+		columns: []string{"asn", "name", "country", "prefixes_v4", "prefixes_v6"},
+		key:     []string{"asn"},
+	},
+	"asn_irr": {
+		columns: []string{"asn", "prefix", "mismatch"},
+		key:     []string{"asn", "prefix"},
+	},
+	"asn_rib": {
+		columns: []string{"asn", "prefix", "mismatch"},
+		key:     []string{"asn", "prefix"},
+	},
+	"prefix_events": {
+		columns: []string{"query", "time", "event", "prefix", "asn"},
+		key:     []string{"query", "time", "event", "prefix"},
+	},
+	"asn_space": {
+		columns: []string{"asn", "total_ipv4", "total_ipv6_48s", "total_ipv6_prefixes", "aggregated"},
+		key:     []string{"asn"},
+	},
+	"found_asns": {
+		columns: []string{"query", "asn", "name"},
+		key:     []string{"query", "asn"},
+	},
+	"abuse": {
+		columns: []string{"asn", "abuse_contact", "note"},
+		key:     []string{"asn"},
+	},
+	"asn_exchanges": {
+		columns: []string{"asn", "ix_name", "location", "ipv4", "ipv6"},
+		key:     []string{"asn", "ix_name", "ipv4", "ipv6"},
+	},
+}
+
+// sqliteWriter stores each result in a SQLite database, one table per kind
+// of result, with the time each row was fetched
+type sqliteWriter struct {
+	db *sql.DB
+}
+
+// openSQLite opens the database at path and creates the tables that do not
+// exist yet
+func openSQLite(path string) (resultWriter, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("-db: %w", err)
+	}
+
+	for name, t := range sqlTables {
+		columns := strings.Join(t.columns, ", ")
+		key := strings.Join(t.key, ", ")
+		stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s, fetched_at TEXT NOT NULL, PRIMARY KEY (%s))",
+			name, columns, key)
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("-db: %w", err)
+		}
+	}
+	return sqliteWriter{db: db}, nil
+}
+
+// Write implements resultWriter. The rows of a result are stored in one
+// transaction.
+func (s sqliteWriter) Write(q query, data interface{}) error {
+	rows, err := sqlRows(q, data)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	fetched := time.Now().UTC().Format(time.RFC3339)
+	for table, values := range rows {
+		t := sqlTables[table]
+		stmt := fmt.Sprintf("INSERT OR REPLACE INTO %s (%s, fetched_at) VALUES (%s?)",
+			table, strings.Join(t.columns, ", "), strings.Repeat("?, ", len(t.columns)))
+		for _, row := range values {
+			if _, err := tx.Exec(stmt, append(row, fetched)...); err != nil {
+				return errors.Join(err, tx.Rollback())
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// Close implements resultWriter
+func (s sqliteWriter) Close() error {
+	return s.db.Close()
+}
+
+// sqlRows returns the rows to store for a result by table. Skipped, failed
+// and empty query statuses store nothing.
+func sqlRows(q query, data interface{}) (map[string][][]interface{}, error) {
+	rows := map[string][][]interface{}{}
+	add := func(table string, values ...interface{}) {
+		rows[table] = append(rows[table], values)
+	}
+
+	switch res := data.(type) {
+	case []ASNInfo:
+		for _, r := range res {
+			add("asn_prefixes", q.Value, r.Prefix, r.Description, r.Country, r.RPKI)
+		}
+	case IPResult:
+		add("ips", res.IP, res.Routed, res.Whois)
+		for _, r := range res.Announcement {
+			add("ip_announcements", res.IP, r.ASN, r.ASNName, r.Network, r.Description,
+				r.Country, r.RPKI, r.Origin, r.ASPath, r.Registry)
+		}
+		for _, r := range res.DNS {
+			add("ip_dns", res.IP, r.IP, r.PTR, r.ARecords)
+		}
+	case []NETInfo:
+		for _, r := range res {
+			add("networks", q.Value, r.ASN, r.Network, r.Description, r.Country,
+				r.RPKI, r.Registry)
+		}
+	case []ORGInfo:
+		for _, r := range res {
+			add("orgs", q.Value, r.Result, r.Type, r.RawType, r.Description, r.Country)
+		}
+	case []FoundASN:
+		for _, r := range res {
+			add("found_asns", q.Value, r.ASN, r.Name)
+		}
+	case GraphInfo:
+		for _, link := range res.Graphs {
+			add("asn_graphs", res.ASN, link)
+		}
+	case ASNStats:
+		add("asn_stats", res.ASN, res.PeersV4, res.PeersV6)
+		add("asn_cones", res.ASN, res.ConeSize, res.Downstreams)
+	case ASNSummary:
+		add("asn_summaries", res.ASN, res.Name, res.Country, res.PrefixesV4, res.PrefixesV6)
+	case IRRDiff:
+		for _, prefix := range res.Unregistered {
+			add("asn_irr", res.ASN, prefix, "announced_not_registered")
+		}
+		for _, prefix := range res.Unannounced {
+			add("asn_irr", res.ASN, prefix, "registered_not_announced")
+		}
+	case RIBDiff:
+		for _, prefix := range res.SiteOnly {
+			add("asn_rib", res.ASN, prefix, "site_only")
+		}
+		for _, prefix := range res.LocalOnly {
+			add("asn_rib", res.ASN, prefix, "local_only")
+		}
+	case ASNSpace:
+		add("asn_space", res.ASN, res.TotalIPv4, res.IPv6Slash48s, res.IPv6Prefixes, res.Aggregated)
+	case []PrefixEvent:
+		for _, r := range res {
+			add("prefix_events", q.Value, r.Time, r.Event, r.Prefix, r.ASN)
+		}
+	case AbuseInfo:
+		add("abuse", res.ASN, res.AbuseContact, res.Note)
+	case []IXInfo:
+		for _, r := range res {
+			add("asn_exchanges", q.Value, r.IXName, r.Location, r.IPv4, r.IPv6)
+		}
+	case queryStatus:
+	default:
+		return nil, fmt.Errorf("-output sqlite cannot store %T results", data)
+	}
+	return rows, nil
+}
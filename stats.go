@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// ASNStats represents the summary counts of an ASN page. A peer count is nil
+// when the page has no summary section, and 0 when the section does not show
+// it. The site shows the customer cone and downstreams of few ASes, so those
+// stay nil, unknown, unless the summary shows them.
+type ASNStats struct {
+	ASN         string `json:"asn"`
+	PeersV4     *int   `json:"peers_v4"`
+	PeersV6     *int   `json:"peers_v6"`
+	ConeSize    *int   `json:"cone_size"`
+	Downstreams *int   `json:"downstreams"`
+}
+
+// summaryCount returns the number following "label:" in the summary section,
+// or 0 when the label is missing or not a number
+func summaryCount(summary *goquery.Selection, label string) *int {
+	n, err := strconv.Atoi(strings.ReplaceAll(labelValue(summary, label), ",", ""))
+	if err != nil {
+		n = 0
+	}
+	return &n
+}
+
+// shownCount returns the number following the first of the labels the
+// summary section shows, ignoring any words after it as in "Customer Cone:
+// 1,024 ASes", or nil when none is shown with a number
+func shownCount(summary *goquery.Selection, labels ...string) *int {
+	for _, label := range labels {
+		fields := strings.Fields(labelValue(summary, label))
+		if len(fields) == 0 {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.ReplaceAll(fields[0], ",", "")); err == nil {
+			return &n
+		}
+	}
+	return nil
+}
+
+// queryStats reads the peer counts of the ASN summary, which is lighter than
+// parsing the peer tables, and its cone and downstream counts when shown
+func queryStats(doc *goquery.Document, q query) ASNStats {
+	stats := ASNStats{ASN: strings.ToUpper(q.Value)}
+	summary := doc.Find("#asinfo")
+	if summary.Length() == 0 {
+		return stats
+	}
+
+	stats.PeersV4 = summaryCount(summary, "BGP Peers Observed (v4)")
+	stats.PeersV6 = summaryCount(summary, "BGP Peers Observed (v6)")
+	stats.ConeSize = shownCount(summary, "Customer Cone", "Cone Size")
+	stats.Downstreams = shownCount(summary, "Downstream ASes", "Downstreams")
+	return stats
+}
+
+// ASNSummary is the header of an ASN page and its prefix counts, printed with
+// -summary-only for comparing many ASNs
+type ASNSummary struct {
+	ASN        string `json:"asn"`
+	Name       string `json:"name"`
+	Country    string `json:"country"`
+	PrefixesV4 int    `json:"prefixes_v4"`
+	PrefixesV6 int    `json:"prefixes_v6"`
+}
+
+// querySummary reads the name and country of the page header and the prefix
+// counts of the summary section, counting the rows of the prefix tables of
+// the -prefix-category categories when the page has no summary. No prefix
+// row is parsed, which keeps large ASNs cheap.
+func querySummary(doc *goquery.Document, q query) ASNSummary {
+	summary := ASNSummary{ASN: strings.ToUpper(q.Value)}
+	summary.Name, summary.Country = asnHeader(doc, summary.ASN)
+
+	if info := doc.Find("#asinfo"); info.Length() > 0 {
+		summary.PrefixesV4 = *summaryCount(info, "Prefixes Originated (v4)")
+		summary.PrefixesV6 = *summaryCount(info, "Prefixes Originated (v6)")
+		return summary
+	}
+	eachPrefixRow(doc, func(row *goquery.Selection, table, category string) {
+		if !matchCategory(category) {
+			return
+		}
+		switch tableFamily(table) {
+		case 4:
+			summary.PrefixesV4++
+		case 6:
+			summary.PrefixesV6++
+		}
+	})
+	return summary
+}
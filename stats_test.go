@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+// intPtr returns a pointer to n, for the counts a page may not show
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestQueryStats(t *testing.T) {
+	tests := []struct {
+		file string
+		asn  string
+		want ASNStats
+	}{
+		{"asn-stats.html", "AS13335", ASNStats{ASN: "AS13335", PeersV4: intPtr(2104), PeersV6: intPtr(1497)}},
+		// the cone size is written with a thousands comma and a unit, 1,024 ASes
+		{"asn-cone.html", "AS64500", ASNStats{ASN: "AS64500", PeersV4: intPtr(300), PeersV6: intPtr(180),
+			ConeSize: intPtr(1024), Downstreams: intPtr(87)}},
+		// no summary section at all
+		{"asn-rpki.html", "as64502", ASNStats{ASN: "AS64502"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			checkResult(t, queryStats(loadFixture(t, tt.file), query{Type: "asn", Value: tt.asn}), tt.want)
+		})
+	}
+}
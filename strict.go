@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// strictTable is the table -strict-html expects on the page of a query type
+// and the cells its parser reads from each row. With every, each table the
+// selector matches is checked and together they must have rows, rather than
+// the first one alone.
+type strictTable struct {
+	selector string
+	cells    int
+	every    bool
+}
+
+// strictTables holds the expected table of each query type. An ASN page
+// needs rows in any of its prefix tables of the -prefix-category categories,
+// so that an AS announcing IPv6 prefixes alone, or only transiting prefixes,
+// passes. Organization searches, and the find-asn queries that run one, use
+// the first table of the page.
+var strictTables = map[string]strictTable{
+	"asn":      {prefixTables, 2, true},
+	"ip":       {"#ipinfo", 3, false},
+	"net":      {"#netinfo", 3, false},
+	"org":      {"table", 3, false},
+	"find-asn": {"table", 3, false},
+}
+
+// strictIXTable is the table -strict-html expects with -at-ix
+var strictIXTable = strictTable{"#ix, #exchanges", 4, false}
+
+// strictPeersTable is the table -strict-html expects with -peer-edges
+var strictPeersTable = strictTable{"#table_peers4, #table_peers6", 5, false}
+
+// strictHistoryTable is the table -strict-html expects with -history
+var strictHistoryTable = strictTable{"#history, #table_history", 3, false}
+
+// checkStrictHTML fails the query when the page does not hold the table its
+// parser expects, the table has no rows, or a row has fewer cells than the
+// parser reads or than the table has header columns. An IP page saying the
+// address is not routed may have no rows. The -select-table table replaces
+// the expected one. -abuse, -graphs, -stats, -summary-only, -irr and -raw-table
+// read no fixed table and are not checked.
+func checkStrictHTML(doc *goquery.Document, q query) error {
+	spec, ok := strictTables[q.Type]
+	if opts.atIX {
+		spec, ok = strictIXTable, true
+	}
+	if opts.peerEdges {
+		spec, ok = strictPeersTable, true
+	}
+	if opts.history {
+		spec, ok = strictHistoryTable, true
+	}
+	if !ok || opts.abuse || opts.graphs || opts.stats || opts.summaryOnly || opts.irr || opts.rawTable {
+		return nil
+	}
+
+	name := spec.selector
+	table, _ := selectTable(doc)
+	switch {
+	case table != nil:
+		name = "-select-table " + opts.selectTable
+	case spec.every:
+		name = "prefix"
+		table = matchedPrefixTables(doc)
+	default:
+		table = doc.Find(spec.selector).First()
+	}
+	if table.Length() == 0 {
+		return fmt.Errorf("strict-html: no %s table on the %s page", name, q.Type)
+	}
+
+	rows := table.Find("tbody tr")
+	if rows.Length() == 0 {
+		if q.Type == "ip" && notRoutedPattern.MatchString(table.Text()) {
+			return nil
+		}
+		return fmt.Errorf("strict-html: the %s table has no rows", name)
+	}
+
+	var short, first, firstCells, want int
+	rows.Each(func(i int, row *goquery.Selection) {
+		// the header of the row's own table, when several are checked
+		header := row.Closest("table").Find("thead th").Length()
+		if n := row.Find("td").Length(); n < max(spec.cells, header) {
+			if short == 0 {
+				first, firstCells, want = i+1, n, max(spec.cells, header)
+			}
+			short++
+		}
+	})
+	if short > 0 {
+		return fmt.Errorf("strict-html: row %d of the %s table has %d cells, want %d (%d short rows)",
+			first, name, firstCells, want, short)
+	}
+	return nil
+}
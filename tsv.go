@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// tsvEscaper writes the characters that would break a TSV line as escapes.
+// The backslash is escaped first so that the escapes can be told apart from
+// text holding a backslash.
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// tsvWriter prints the results as tab-separated values, laid out in columns
+// like the -interactive table with the query value first. A header line is
+// printed before the first result and again whenever the columns change, so
+// that a batch mixing query types stays readable.
+type tsvWriter struct {
+	w      io.Writer
+	header string
+}
+
+// Write implements resultWriter
+func (t *tsvWriter) Write(q query, data interface{}) error {
+	header, rows := tableOf(data)
+	line := tsvLine(append([]string{"query"}, header...))
+	if line != t.header {
+		if _, err := fmt.Fprintln(t.w, line); err != nil {
+			return err
+		}
+		t.header = line
+	}
+	for _, row := range rows {
+		if _, err := fmt.Fprintln(t.w, tsvLine(append([]string{q.Value}, row...))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close implements resultWriter
+func (t *tsvWriter) Close() error {
+	return nil
+}
+
+// tsvLine joins the escaped cells of a line with tabs
+func tsvLine(cells []string) string {
+	escaped := make([]string, len(cells))
+	for i, cell := range cells {
+		escaped[i] = tsvEscaper.Replace(cell)
+	}
+	return strings.Join(escaped, "\t")
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// updatedPattern matches the time the routing data was last updated, shown
+// in the page footer as "Updated 14 Oct 2026 04:12 PST"
+var updatedPattern = regexp.MustCompile(`(?i)(?:last )?updated:?\s+(\d{1,2} [a-z]{3} \d{4} \d{1,2}:\d{2}(?::\d{2})?)(?:\s+([a-z]{1,5}))?`)
+
+// zoneOffsets holds the offsets from UTC of the zone abbreviations the site
+// writes times in, in hours
+var zoneOffsets = map[string]int{
+	"UTC": 0, "GMT": 0, "Z": 0,
+	"EST": -5, "EDT": -4, "CST": -6, "CDT": -5,
+	"MST": -7, "MDT": -6, "PST": -8, "PDT": -7,
+}
+
+// dataUpdated returns the time the routing data of the page was last
+// updated in RFC 3339, or an empty string when the page does not show it. A
+// time without a known zone is taken as UTC.
+func dataUpdated(doc *goquery.Document) string {
+	text := doc.Find("#footer").Text()
+	if text == "" {
+		text = doc.Text()
+	}
+	m := updatedPattern.FindStringSubmatch(strings.Join(strings.Fields(text), " "))
+	if m == nil {
+		return ""
+	}
+
+	layout := "2 Jan 2006 15:04"
+	if strings.Count(m[1], ":") == 2 {
+		layout += ":05"
+	}
+	t, err := time.Parse(layout, m[1])
+	if err != nil {
+		return ""
+	}
+	if offset, ok := zoneOffsets[strings.ToUpper(m[2])]; ok {
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0,
+			time.FixedZone(strings.ToUpper(m[2]), offset*3600))
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,17 @@
+package main
+
+import "testing"
+
+func TestDataUpdated(t *testing.T) {
+	tests := []struct {
+		file, want string
+	}{
+		{"asn-updated.html", "2026-10-14T04:12:00-08:00"},
+		{"asn-rpki.html", ""},
+	}
+	for _, tt := range tests {
+		if got := dataUpdated(loadFixture(t, tt.file)); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.file, got, tt.want)
+		}
+	}
+}
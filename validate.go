@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// asnPattern matches an AS number as shown on the BGP website
+var asnPattern = regexp.MustCompile(`^AS\d+$`)
+
+// validateASN checks that the value looks like an AS number
+func validateASN(asn string) error {
+	if !asnPattern.MatchString(asn) {
+		return fmt.Errorf("asn %q does not match AS<number>", asn)
+	}
+	return nil
+}
+
+// validateCIDR checks that the value parses as a network block
+func validateCIDR(field, value string) error {
+	if _, _, err := net.ParseCIDR(value); err != nil {
+		return fmt.Errorf("%s %q is not a CIDR", field, value)
+	}
+	return nil
+}
+
+// addressLike matches values made of the characters of an address or network
+// block, digits, dots and a slash, or hex digits around a colon, that are
+// never meant as an organization name
+var addressLike = regexp.MustCompile(`^[0-9./]+$|^[0-9A-Fa-f.:/]*:[0-9A-Fa-f.:/]*$`)
+
+// validateQuery checks that the value of a query has the shape of its type,
+// without querying anything: an AS number within 32 bits, an IP address or
+// a network block. Organization searches take any value, except one that
+// looks like a mistyped address or network block, which the batch parser
+// would have detected as an IP or a CIDR had it parsed.
+func validateQuery(q query) error {
+	switch q.Type {
+	case "asn":
+		upper := strings.ToUpper(q.Value)
+		if !asnPattern.MatchString(upper) {
+			return fmt.Errorf("asn %q does not match AS<number>", q.Value)
+		}
+		if _, err := strconv.ParseUint(upper[2:], 10, 32); err != nil {
+			return fmt.Errorf("asn %q is not a 32-bit AS number", q.Value)
+		}
+	case "ip":
+		_, err := normalizeQuery(q)
+		return err
+	case "net":
+		return validateCIDR("network", q.Value)
+	case "org", "find-asn":
+		if q.Value == "" {
+			return errors.New("missing value")
+		}
+		if addressLike.MatchString(q.Value) {
+			return fmt.Errorf("%q is neither an IP address nor a CIDR", q.Value)
+		}
+	default:
+		return fmt.Errorf("unknown type %q", q.Type)
+	}
+	return nil
+}
+
+// validateQueries prints the status of each query whose value fails
+// validateQuery, as invalid, and returns how many did
+func validateQueries(queries []query) int {
+	invalid := 0
+	for _, q := range queries {
+		if err := validateQuery(q); err != nil {
+			log.Printf("%s %s: %v", q.Type, q.Value, err)
+			writeStatus(q, "invalid", err)
+			invalid++
+		}
+	}
+	return invalid
+}
+
+// validate checks the fields of an IP announcement row
+func (i IPInfo) validate() error {
+	return errors.Join(validateASN(i.ASN), validateCIDR("network", i.Network))
+}
+
+// validate checks the fields of a DNS record row
+func (d DNSInfo) validate() error {
+	if net.ParseIP(d.IP) == nil {
+		return fmt.Errorf("ip %q is not an IP address", d.IP)
+	}
+	return nil
+}
+
+// validate checks the fields of a network block row
+func (n NETInfo) validate() error {
+	return errors.Join(validateASN(n.ASN), validateCIDR("network", n.Network))
+}
+
+// validate checks the fields of an ASN prefix row
+func (a ASNInfo) validate() error {
+	return validateCIDR("prefix", a.Prefix)
+}
+
+// validate checks the fields of an organization search row
+func (o ORGInfo) validate() error {
+	if o.Result == "" || o.RawType == "" {
+		return errors.New("result and type must not be empty")
+	}
+	if o.Type == orgTypeUnknown {
+		return fmt.Errorf("unknown type %q", o.RawType)
+	}
+	return nil
+}
+
+// validateResult checks every row of a parsed query result against its field
+// constraints and returns a warning for each row that fails.
+func validateResult(data interface{}) []string {
+	var warnings []string
+	check := func(section string, i int, err error) {
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s row %d: %v", section, i, err))
+		}
+	}
+
+	switch res := data.(type) {
+	case IPResult:
+		for i, row := range res.Announcement {
+			check("announcement", i, row.validate())
+		}
+		for i, row := range res.DNS {
+			check("dns", i, row.validate())
+		}
+	case []NETInfo:
+		for i, row := range res {
+			check("net", i, row.validate())
+		}
+	case []ASNInfo:
+		for i, row := range res {
+			check("asn", i, row.validate())
+		}
+	case []ORGInfo:
+		for i, row := range res {
+			check("org", i, row.validate())
+		}
+	}
+
+	return warnings
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+// maxWrapLine is the longest line -wrap reads, enough for the result of a
+// page of the largest -max-body-size in common use
+const maxWrapLine = 64 << 20
+
+// wrapLines reads JSON lines, such as the output of several runs, and prints
+// them as one JSON array, the form consumers expecting a single document
+// read. Blank lines are ignored. Every line is checked before anything is
+// printed, so a line that is not valid JSON fails with its number and no
+// array is written. It returns the exit code.
+func wrapLines(r io.Reader, w io.Writer) int {
+	values := []json.RawMessage{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxWrapLine)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var value json.RawMessage
+		if err := json.Unmarshal([]byte(line), &value); err != nil {
+			log.Printf("-wrap: line %d is not valid JSON: %v: %s", n, err, excerpt(line))
+			return exitFailure
+		}
+		values = append(values, value)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("-wrap: %v", err)
+		return exitFailure
+	}
+
+	jsonData, err := json.Marshal(values)
+	if err == nil {
+		_, err = fmt.Fprintln(w, string(jsonData))
+	}
+	if err != nil {
+		log.Printf("-wrap: %v", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// excerpt returns the start of a line for an error message
+func excerpt(line string) string {
+	if runes := []rune(line); len(runes) > 60 {
+		return string(runes[:60]) + "..."
+	}
+	return line
+}